@@ -0,0 +1,415 @@
+// Package alt4grpc provides gRPC interceptors that write to alt4.
+// Server interceptors open a log group per RPC and client interceptors log outgoing calls.
+// The thread id of the caller's group is propagated through metadata to trusted services so that the callee continues the same group.
+package alt4grpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/go/tracecontext"
+	"github.com/alt4dev/protobuff/proto"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ThreadMetadataKey is the metadata key used to propagate the thread id of a log group between services.
+const ThreadMetadataKey = "alt4-thread"
+
+// Options configure the interceptors.
+type Options struct {
+	// Levels maps a status code to the level used to log failed calls.
+	// Codes not found default to WARNING for client errors e.g. NotFound and ERROR for server errors e.g. Internal.
+	Levels map[codes.Code]proto.Log_Level
+	// TrustThreadMetadata makes server interceptors continue the thread received in `alt4-thread` metadata
+	// or with TraceContext the trace received in `traceparent` metadata. Callers can add their entries to any thread
+	// so only set it for servers called by trusted services.
+	TrustThreadMetadata bool
+	// PropagateHosts the hosts of the targets client interceptors send the thread id of the current group to, in `alt4-thread` metadata
+	// and with TraceContext `traceparent` metadata. Hosts starting with a `.` match subdomains e.g. `.internal.example.com`.
+	// The thread id isn't sent to other targets.
+	PropagateHosts []string
+	// TraceContext enables W3C Trace Context through `traceparent` metadata. Server interceptors adopt the trace id received,
+	// if TrustThreadMetadata is set, as the thread id of the RPC's group and client interceptors send the thread id of the current group as the trace id.
+	// The trace id, span id and parent span id are recorded as claims.
+	TraceContext bool
+}
+
+var defaultLevels = map[codes.Code]proto.Log_Level{
+	codes.OK:                 proto.Log_INFO,
+	codes.Canceled:           proto.Log_WARNING,
+	codes.InvalidArgument:    proto.Log_WARNING,
+	codes.NotFound:           proto.Log_WARNING,
+	codes.AlreadyExists:      proto.Log_WARNING,
+	codes.PermissionDenied:   proto.Log_WARNING,
+	codes.ResourceExhausted:  proto.Log_WARNING,
+	codes.FailedPrecondition: proto.Log_WARNING,
+	codes.Aborted:            proto.Log_WARNING,
+	codes.OutOfRange:         proto.Log_WARNING,
+	codes.Unauthenticated:    proto.Log_WARNING,
+}
+
+func (opts Options) level(code codes.Code) proto.Log_Level {
+	if level, ok := opts.Levels[code]; ok {
+		return level
+	}
+	if level, ok := defaultLevels[code]; ok {
+		return level
+	}
+	return proto.Log_ERROR
+}
+
+func peerAddress(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// openGroup opens the group of an RPC continuing the caller's thread if one was received and it's trusted.
+// The returned context carries the span of the RPC when trace context is enabled.
+func (opts Options) openGroup(ctx context.Context, claims log.Claims, method string) (context.Context, *log.GroupResult) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	threadId := ""
+	if opts.TrustThreadMetadata {
+		threadId = firstValue(md, ThreadMetadataKey)
+	}
+	if opts.TraceContext {
+		var span tracecontext.SpanContext
+		if parent, err := tracecontext.Parse(firstValue(md, tracecontext.Header)); err == nil && opts.TrustThreadMetadata {
+			span = parent.Child()
+		} else {
+			span = tracecontext.NewSpan(uuid.New().String())
+		}
+		threadId = span.ThreadId()
+		for key, value := range span.Claims() {
+			claims[key] = value
+		}
+		ctx = tracecontext.ContextWithSpan(ctx, span)
+	}
+	if threadId != "" {
+		return ctx, claims.JoinGroup(threadId, method)
+	}
+	return ctx, claims.Group(method)
+}
+
+// outgoing adds the thread id of the current group and the trace context to the outgoing metadata if target is in PropagateHosts.
+// Trace claims are added to claims.
+func (opts Options) outgoing(ctx context.Context, target string, claims log.Claims) context.Context {
+	threadId, grouped := service.ThreadId()
+	propagate := opts.propagates(targetHost(target))
+	if grouped && propagate {
+		ctx = metadata.AppendToOutgoingContext(ctx, ThreadMetadataKey, threadId)
+	}
+	if opts.TraceContext {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		span, err := tracecontext.Parse(firstValue(md, tracecontext.Header))
+		if err != nil {
+			span = tracecontext.Outgoing(ctx, threadId)
+			if propagate {
+				ctx = metadata.AppendToOutgoingContext(ctx, tracecontext.Header, span.Traceparent())
+			}
+		}
+		for key, value := range span.Claims() {
+			claims[key] = value
+		}
+	}
+	return ctx
+}
+
+// propagates reports whether the thread id is sent to host.
+func (opts Options) propagates(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range opts.PropagateHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, ".") && (strings.HasSuffix(host, allowed) || host == allowed[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+// targetHost returns the host of a dial target e.g. `orders` for `dns:///orders:443`.
+func targetHost(target string) string {
+	if i := strings.Index(target, "://"); i >= 0 {
+		target = target[i+len("://"):]
+		target = target[strings.LastIndex(target, "/")+1:]
+	}
+	if host, _, err := net.SplitHostPort(target); err == nil {
+		return host
+	}
+	return strings.Trim(target, "[]")
+}
+
+// callSummary is formatted when the group of an RPC is closed.
+type callSummary struct {
+	method   string
+	code     codes.Code
+	duration time.Duration
+}
+
+func (summary *callSummary) String() string {
+	return fmt.Sprintf("%s %s %s", summary.method, summary.code, summary.duration)
+}
+
+// UnaryServerInterceptor returns an interceptor that serves every unary RPC within its own log group.
+// Example: grpc.NewServer(grpc.UnaryInterceptor(alt4grpc.UnaryServerInterceptor(alt4grpc.Options{})))
+func UnaryServerInterceptor(opts Options) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		claims := log.Claims{
+			"method": info.FullMethod,
+			"peer":   peerAddress(ctx),
+		}
+		summary := &callSummary{method: info.FullMethod, code: codes.Internal}
+		ctx, group := opts.openGroup(ctx, claims, info.FullMethod)
+		// Close has to be deferred directly for it to log panics from the handler.
+		defer group.Close(summary)
+		defer func() {
+			summary.duration = time.Since(start)
+			claims["code"] = summary.code.String()
+			claims["duration_ms"] = float64(summary.duration) / float64(time.Millisecond)
+			claims["messages_received"] = 1
+			claims["messages_sent"] = 0
+			if summary.code == codes.OK {
+				claims["messages_sent"] = 1
+			}
+		}()
+
+		resp, err := handler(ctx, req)
+		summary.code = status.Code(err)
+		if err != nil {
+			log.Claims{"method": info.FullMethod, "code": summary.code.String()}.Log(opts.level(summary.code), info.FullMethod, " failed: ", err)
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that serves every streaming RPC within its own log group.
+// Example: grpc.NewServer(grpc.StreamInterceptor(alt4grpc.StreamServerInterceptor(alt4grpc.Options{})))
+func StreamServerInterceptor(opts Options) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := ss.Context()
+		claims := log.Claims{
+			"method": info.FullMethod,
+			"peer":   peerAddress(ctx),
+		}
+		summary := &callSummary{method: info.FullMethod, code: codes.Internal}
+		ctx, group := opts.openGroup(ctx, claims, info.FullMethod)
+		stream := &serverStream{ServerStream: ss, ctx: ctx}
+		// Close has to be deferred directly for it to log panics from the handler.
+		defer group.Close(summary)
+		defer func() {
+			summary.duration = time.Since(start)
+			claims["code"] = summary.code.String()
+			claims["duration_ms"] = float64(summary.duration) / float64(time.Millisecond)
+			claims["messages_received"] = atomic.LoadInt64(&stream.received)
+			claims["messages_sent"] = atomic.LoadInt64(&stream.sent)
+		}()
+
+		err := handler(srv, stream)
+		summary.code = status.Code(err)
+		if err != nil {
+			log.Claims{"method": info.FullMethod, "code": summary.code.String()}.Log(opts.level(summary.code), info.FullMethod, " failed: ", err)
+		}
+		return err
+	}
+}
+
+// serverStream counts the messages sent and received on a stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	sent     int64
+	received int64
+}
+
+// Context returns the context of the stream. It carries the span of the RPC when trace context is enabled.
+func (stream *serverStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *serverStream) SendMsg(m interface{}) error {
+	err := stream.ServerStream.SendMsg(m)
+	if err == nil {
+		atomic.AddInt64(&stream.sent, 1)
+	}
+	return err
+}
+
+func (stream *serverStream) RecvMsg(m interface{}) error {
+	err := stream.ServerStream.RecvMsg(m)
+	if err == nil {
+		atomic.AddInt64(&stream.received, 1)
+	}
+	return err
+}
+
+// UnaryClientInterceptor returns an interceptor that logs every outgoing unary call within the caller's group.
+// Example: grpc.Dial(target, grpc.WithUnaryInterceptor(alt4grpc.UnaryClientInterceptor(alt4grpc.Options{})))
+func UnaryClientInterceptor(opts Options) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		claims := log.Claims{
+			"method": method,
+			"target": target(cc),
+		}
+		ctx = opts.outgoing(ctx, target(cc), claims)
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+		duration := time.Since(start)
+		code := status.Code(err)
+		claims["code"] = code.String()
+		claims["duration_ms"] = float64(duration) / float64(time.Millisecond)
+		if err != nil {
+			claims["error"] = err.Error()
+		}
+		claims.Log(opts.level(code), fmt.Sprintf("%s %s (%s)", method, code, duration))
+		return err
+	}
+}
+
+// StreamClientInterceptor returns an interceptor that logs outgoing streaming calls.
+// The call is logged once the stream ends, together with the number of messages sent and received, within the group of the goroutine that opened it.
+// A stream ends when the response is read to the end, an error is returned or the context of the call is cancelled.
+// Example: grpc.Dial(target, grpc.WithStreamInterceptor(alt4grpc.StreamClientInterceptor(alt4grpc.Options{})))
+func StreamClientInterceptor(opts Options) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		claims := log.Claims{
+			"method": method,
+			"target": target(cc),
+		}
+		ctx = opts.outgoing(ctx, target(cc), claims)
+		threadId, _ := service.ThreadId()
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, callOpts...)
+		stream := &clientStream{
+			opts:     opts,
+			method:   method,
+			claims:   claims,
+			threadId: threadId,
+			start:    start,
+			single:   !desc.ServerStreams,
+			done:     make(chan struct{}),
+		}
+		if err != nil {
+			stream.finish(err)
+			return nil, err
+		}
+		stream.ClientStream = cs
+		go stream.watch(ctx)
+		return stream, nil
+	}
+}
+
+// clientStream counts the messages sent and received on a stream and logs the call once it ends.
+type clientStream struct {
+	grpc.ClientStream
+	opts     Options
+	method   string
+	claims   log.Claims
+	threadId string
+	start    time.Time
+	// single is set for streams with a single response e.g. client streaming calls. The call ends once the response is received.
+	single   bool
+	sent     int64
+	received int64
+	finished int32
+	done     chan struct{}
+}
+
+// watch finishes the call if it's cancelled before the stream ends.
+func (stream *clientStream) watch(ctx context.Context) {
+	select {
+	case <-stream.done:
+	case <-stream.ClientStream.Context().Done():
+		// The stream's context is also cancelled when the call ends normally in which case the status is read with RecvMsg
+		if ctx.Err() != nil {
+			stream.finish(status.FromContextError(ctx.Err()).Err())
+		}
+	}
+}
+
+func (stream *clientStream) SendMsg(m interface{}) error {
+	err := stream.ClientStream.SendMsg(m)
+	if err == nil {
+		atomic.AddInt64(&stream.sent, 1)
+	}
+	return err
+}
+
+// CloseSend closes the sending side of the stream. The call is finished if closing fails.
+func (stream *clientStream) CloseSend() error {
+	err := stream.ClientStream.CloseSend()
+	if err != nil {
+		stream.finish(err)
+	}
+	return err
+}
+
+func (stream *clientStream) RecvMsg(m interface{}) error {
+	err := stream.ClientStream.RecvMsg(m)
+	if err == nil {
+		atomic.AddInt64(&stream.received, 1)
+		if stream.single {
+			stream.finish(nil)
+		}
+		return nil
+	}
+	if err == io.EOF {
+		stream.finish(nil)
+	} else {
+		stream.finish(err)
+	}
+	return err
+}
+
+// finish logs the call once. The entry is written in the thread of the goroutine that opened the stream
+// since the stream can end in any goroutine.
+func (stream *clientStream) finish(err error) {
+	if !atomic.CompareAndSwapInt32(&stream.finished, 0, 1) {
+		return
+	}
+	close(stream.done)
+	duration := time.Since(stream.start)
+	code := status.Code(err)
+	claims := stream.claims
+	claims["code"] = code.String()
+	claims["duration_ms"] = float64(duration) / float64(time.Millisecond)
+	claims["messages_sent"] = atomic.LoadInt64(&stream.sent)
+	claims["messages_received"] = atomic.LoadInt64(&stream.received)
+	if err != nil {
+		claims["error"] = err.Error()
+	}
+	service.LogEntry(&proto.Log{
+		Message:   fmt.Sprintf("%s %s (%s)", stream.method, code, duration),
+		Claims:    claims.ProtoClaims(),
+		Level:     stream.opts.level(code),
+		Timestamp: uint64(service.LogTime().UnixNano()),
+		Thread:    stream.threadId,
+	})
+}
+
+func target(cc *grpc.ClientConn) string {
+	if cc == nil {
+		return ""
+	}
+	return cc.Target()
+}
@@ -0,0 +1,328 @@
+package alt4grpc
+
+import (
+	"context"
+	"errors"
+	"github.com/alt4dev/go/alt4test"
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/go/tracecontext"
+	"github.com/alt4dev/protobuff/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUnaryInterceptors(t *testing.T) {
+	helper := alt4test.Record()
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.UnaryInterceptor(UnaryServerInterceptor(Options{TrustThreadMetadata: true})))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(Options{PropagateHosts: []string{"bufnet"}})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	group := log.Group("Checking health")
+	threadId, _ := service.ThreadId()
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	group.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var serverGroup, serverClose, clientCall *proto.Log
+	for _, msg := range helper.Entries() {
+		switch {
+		case msg.Group && msg.Message == "/grpc.health.v1.Health/Check":
+			serverGroup = msg
+		case msg.Level == proto.Log_NONE && alt4test.ClaimValue(msg, "peer") != "":
+			serverClose = msg
+		case alt4test.ClaimValue(msg, "target") == "bufnet":
+			clientCall = msg
+		}
+	}
+	if serverGroup == nil || serverClose == nil || clientCall == nil {
+		t.Fatalf("Expected server and client entries. Found %v", helper.Entries())
+	}
+	if serverGroup.Thread != threadId || clientCall.Thread != threadId {
+		t.Error("Expected the server group to continue the client's thread")
+	}
+	if alt4test.ClaimValue(serverClose, "code") != "OK" || alt4test.ClaimValue(serverClose, "messages_received") != "1" || alt4test.ClaimValue(serverClose, "messages_sent") != "1" {
+		t.Errorf("Unexpected claims on close. %v", serverClose.Claims)
+	}
+	if alt4test.ClaimValue(clientCall, "code") != "OK" || clientCall.Level != proto.Log_INFO {
+		t.Errorf("Unexpected client entry. %v", clientCall)
+	}
+}
+
+func TestUnaryServerInterceptorError(t *testing.T) {
+	helper := alt4test.Record()
+	interceptor := UnaryServerInterceptor(Options{Levels: map[codes.Code]proto.Log_Level{codes.NotFound: proto.Log_DEBUG}, TrustThreadMetadata: true})
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ThreadMetadataKey, "thread-from-caller"))
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "missing")
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Fatal("Expected the handler's error to be returned")
+	}
+	var failure *proto.Log
+	for _, msg := range helper.Entries() {
+		if msg.Thread != "thread-from-caller" {
+			t.Errorf("Expected all entries to continue the caller's thread. %v", msg)
+		}
+		if msg.Level == proto.Log_DEBUG {
+			failure = msg
+		}
+	}
+	if failure == nil || alt4test.ClaimValue(failure, "code") != "NotFound" {
+		t.Errorf("Expected the failure to be logged at the configured level. Found %v", helper.Entries())
+	}
+}
+
+type serverStreamMock struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *serverStreamMock) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *serverStreamMock) SendMsg(m interface{}) error {
+	return nil
+}
+
+func (stream *serverStreamMock) RecvMsg(m interface{}) error {
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	helper := alt4test.Record()
+	interceptor := StreamServerInterceptor(Options{})
+	stream := &serverStreamMock{ctx: context.Background()}
+	err := interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/test.Service/Stream"}, func(srv interface{}, stream grpc.ServerStream) error {
+		_ = stream.RecvMsg(nil)
+		_ = stream.SendMsg(nil)
+		_ = stream.SendMsg(nil)
+		return errors.New("stream failed")
+	})
+	if err == nil {
+		t.Fatal("Expected the handler's error to be returned")
+	}
+	var closing *proto.Log
+	for _, msg := range helper.Entries() {
+		if msg.Level == proto.Log_NONE && !msg.Group {
+			closing = msg
+		}
+	}
+	if closing == nil {
+		t.Fatalf("Expected the group to be closed with a summary. Found %v", helper.Entries())
+	}
+	if alt4test.ClaimValue(closing, "messages_received") != "1" || alt4test.ClaimValue(closing, "messages_sent") != "2" || alt4test.ClaimValue(closing, "code") != "Unknown" {
+		t.Errorf("Unexpected claims on close. %v", closing.Claims)
+	}
+}
+
+type clientStreamMock struct {
+	grpc.ClientStream
+	ctx      context.Context
+	messages int
+}
+
+func (stream *clientStreamMock) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *clientStreamMock) SendMsg(m interface{}) error {
+	return nil
+}
+
+func (stream *clientStreamMock) CloseSend() error {
+	return nil
+}
+
+func (stream *clientStreamMock) RecvMsg(m interface{}) error {
+	if stream.ctx.Err() != nil {
+		return status.FromContextError(stream.ctx.Err()).Err()
+	}
+	if stream.messages == 0 {
+		return io.EOF
+	}
+	stream.messages--
+	return nil
+}
+
+func openClientStream(ctx context.Context, desc *grpc.StreamDesc, messages int) grpc.ClientStream {
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &clientStreamMock{ctx: ctx, messages: messages}, nil
+	}
+	stream, _ := StreamClientInterceptor(Options{})(ctx, desc, nil, "/test.Service/Stream", streamer)
+	return stream
+}
+
+// waitForEntries waits for the entries written from other goroutines
+func waitForEntries(helper *alt4test.Recorder, count int) []*proto.Log {
+	deadline := time.Now().Add(time.Second)
+	for len(helper.Entries()) < count && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	return helper.Entries()
+}
+
+func TestStreamClientInterceptor(t *testing.T) {
+	helper := alt4test.Record()
+	group := log.Group("Streaming")
+	threadId, _ := service.ThreadId()
+	stream := openClientStream(context.Background(), &grpc.StreamDesc{ServerStreams: true}, 2)
+	_ = stream.SendMsg(nil)
+	// The stream is read to the end from another goroutine
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for stream.RecvMsg(nil) == nil {
+		}
+	}()
+	<-finished
+	group.Close()
+
+	var call *proto.Log
+	for _, msg := range waitForEntries(helper, 2) {
+		if !msg.Group {
+			call = msg
+		}
+	}
+	if call == nil || call.Thread != threadId {
+		t.Fatalf("Expected the call to be logged within the group that opened the stream. Found %v", helper.Entries())
+	}
+	if alt4test.ClaimValue(call, "code") != "OK" || alt4test.ClaimValue(call, "messages_sent") != "1" || alt4test.ClaimValue(call, "messages_received") != "2" {
+		t.Errorf("Unexpected claims %v", call.Claims)
+	}
+}
+
+func TestStreamClientInterceptorCancel(t *testing.T) {
+	helper := alt4test.Record()
+	ctx, cancel := context.WithCancel(context.Background())
+	_ = openClientStream(ctx, &grpc.StreamDesc{ServerStreams: true}, 1)
+	// The stream is abandoned without being read
+	cancel()
+	logs := waitForEntries(helper, 1)
+	if len(logs) != 1 || alt4test.ClaimValue(logs[0], "code") != "Canceled" {
+		t.Fatalf("Expected the cancelled call to be logged. Found %v", logs)
+	}
+}
+
+func TestStreamClientInterceptorClientStreaming(t *testing.T) {
+	helper := alt4test.Record()
+	stream := openClientStream(context.Background(), &grpc.StreamDesc{ClientStreams: true}, 1)
+	_ = stream.SendMsg(nil)
+	_ = stream.CloseSend()
+	_ = stream.RecvMsg(nil)
+	logs := waitForEntries(helper, 1)
+	if len(logs) != 1 || alt4test.ClaimValue(logs[0], "code") != "OK" || alt4test.ClaimValue(logs[0], "messages_received") != "1" {
+		t.Fatalf("Expected the call to end with its response. Found %v", logs)
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	helper := alt4test.Record()
+	opts := Options{TraceContext: true, TrustThreadMetadata: true, PropagateHosts: []string{".internal"}}
+	client := UnaryClientInterceptor(opts)
+	cc, err := grpc.Dial("dns:///orders.internal:443", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+	var outgoing metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, callOpts ...grpc.CallOption) error {
+		outgoing, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(tracecontext.Header, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"))
+	_, _ = UnaryServerInterceptor(opts)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, client(ctx, "/test.Downstream/Get", nil, nil, cc, invoker)
+	})
+
+	for _, msg := range helper.Entries() {
+		if msg.Thread != "4bf92f35-77b3-4da6-a3ce-929d0e0e4736" {
+			t.Errorf("Expected the trace id to be adopted as the thread id. Found %s", msg.Thread)
+		}
+	}
+	span, err := tracecontext.Parse(firstValue(outgoing, tracecontext.Header))
+	if err != nil || span.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the outgoing call to continue the trace. Found %v", outgoing)
+	}
+	if firstValue(outgoing, ThreadMetadataKey) != "4bf92f35-77b3-4da6-a3ce-929d0e0e4736" {
+		t.Errorf("Expected the thread id to be propagated. Found %v", outgoing)
+	}
+}
+
+func TestTraceContextUntrusted(t *testing.T) {
+	helper := alt4test.Record()
+	opts := Options{TraceContext: true}
+	md := metadata.Pairs(tracecontext.Header, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ThreadMetadataKey, "thread-from-caller")
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	_, _ = UnaryServerInterceptor(opts)(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test.Service/Get"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+
+	logs := helper.Entries()
+	if len(logs) == 0 {
+		t.Fatal("Expected the call to be logged")
+	}
+	for _, msg := range logs {
+		if msg.Thread == "4bf92f35-77b3-4da6-a3ce-929d0e0e4736" || msg.Thread == "thread-from-caller" {
+			t.Errorf("Expected the received thread to be ignored without TrustThreadMetadata. Found %s", msg.Thread)
+		}
+	}
+}
+
+func TestPropagateHosts(t *testing.T) {
+	alt4test.Record()
+	opts := Options{TraceContext: true, PropagateHosts: []string{"orders", ".internal.example.com"}}
+	expected := map[string]bool{
+		"orders:443":    true,
+		"dns:///orders": true,
+		"dns://8.8.8.8/billing.internal.example.com:443": true,
+		"passthrough:///internal.example.com":            true,
+		"thirdparty.com:443":                             false,
+		"dns:///orders.thirdparty.com":                   false,
+		"[::1]:50051":                                    false,
+	}
+	group := log.Group("Calling services")
+	defer group.Close()
+	for target, propagated := range expected {
+		cc, err := grpc.Dial(target, grpc.WithInsecure())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var outgoing metadata.MD
+		_ = UnaryClientInterceptor(opts)(context.Background(), "/test.Service/Get", nil, nil, cc, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, callOpts ...grpc.CallOption) error {
+			outgoing, _ = metadata.FromOutgoingContext(ctx)
+			return nil
+		})
+		cc.Close()
+		sent := firstValue(outgoing, ThreadMetadataKey) != "" && firstValue(outgoing, tracecontext.Header) != ""
+		if sent != propagated || (!propagated && len(outgoing) != 0) {
+			t.Errorf("Expected the thread id to be sent to %s: %v. Found %v", target, propagated, outgoing)
+		}
+	}
+}
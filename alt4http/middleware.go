@@ -0,0 +1,213 @@
+// Package alt4http provides net/http integrations that write to alt4.
+package alt4http
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/go/tracecontext"
+	"github.com/google/uuid"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultRequestIdHeader is the header used to read the request id when Options.RequestIdHeader is not set.
+const DefaultRequestIdHeader = "X-Request-Id"
+
+// Options configure the middleware returned by NewMiddleware.
+type Options struct {
+	// Route returns the route recorded for a request. Defaults to the path of the request URL.
+	// Set this when using a router so that requests served by the same handler share a route e.g. `/users/{id}`
+	Route func(r *http.Request) string
+	// RequestIdHeader the header to read the request id from. Defaults to `X-Request-Id`.
+	// A new id is generated when the header is missing.
+	RequestIdHeader string
+	// Claims optionally returns extra claims to attach to the group of a request.
+	Claims func(r *http.Request) log.Claims
+	// TrustThreadHeader continues the thread received in the `Alt4-Thread` header, or with TraceContext the `traceparent` header,
+	// so that logs from the caller and the callee are grouped together.
+	// Only set this for services whose clients are trusted e.g. internal services, otherwise any client can add its logs to another request's thread.
+	TrustThreadHeader bool
+	// TraceContext enables W3C Trace Context. With TrustThreadHeader, the trace id received in the `traceparent` header is adopted as the thread id
+	// of the request's group. The trace id, span id and parent span id are recorded as claims.
+	// A new trace is started for requests without a valid or trusted `traceparent` header.
+	TraceContext bool
+}
+
+type contextKey struct{}
+
+// GroupFromContext returns the log group opened by the middleware for the current request.
+// Returns nil if the context doesn't belong to a request served by the middleware.
+func GroupFromContext(ctx context.Context) *log.GroupResult {
+	group, _ := ctx.Value(contextKey{}).(*log.GroupResult)
+	return group
+}
+
+// Middleware wraps a handler so that every request is served within its own log group.
+// Example: http.ListenAndServe(":8080", alt4http.Middleware(mux))
+func Middleware(next http.Handler) http.Handler {
+	return NewMiddleware(Options{})(next)
+}
+
+// NewMiddleware returns a middleware configured by opts.
+// The returned function can be used with routers that accept `func(http.Handler) http.Handler` middleware.
+func NewMiddleware(opts Options) func(http.Handler) http.Handler {
+	if opts.Route == nil {
+		opts.Route = func(r *http.Request) string {
+			return r.URL.Path
+		}
+	}
+	if opts.RequestIdHeader == "" {
+		opts.RequestIdHeader = DefaultRequestIdHeader
+	}
+	return func(next http.Handler) http.Handler {
+		return &middleware{next: next, opts: opts}
+	}
+}
+
+type middleware struct {
+	next http.Handler
+	opts Options
+}
+
+func (m *middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	route := m.opts.Route(r)
+	requestId := r.Header.Get(m.opts.RequestIdHeader)
+	if requestId == "" {
+		requestId = uuid.New().String()
+	}
+	claims := log.Claims{
+		"method":      r.Method,
+		"route":       route,
+		"remote_addr": r.RemoteAddr,
+		"user_agent":  r.UserAgent(),
+		"request_id":  requestId,
+	}
+	if m.opts.Claims != nil {
+		for key, value := range m.opts.Claims(r) {
+			claims[key] = value
+		}
+	}
+
+	recorder := &responseRecorder{ResponseWriter: w}
+	summary := &requestSummary{method: r.Method, route: route, recorder: recorder}
+	ctx := r.Context()
+	threadId := ""
+	if m.opts.TrustThreadHeader {
+		threadId = r.Header.Get(ThreadHeader)
+	}
+	if m.opts.TraceContext {
+		var span tracecontext.SpanContext
+		if parent, err := tracecontext.Parse(r.Header.Get(tracecontext.Header)); err == nil && m.opts.TrustThreadHeader {
+			span = parent.Child()
+		} else {
+			span = tracecontext.NewSpan(uuid.New().String())
+		}
+		threadId = span.ThreadId()
+		for key, value := range span.Claims() {
+			claims[key] = value
+		}
+		ctx = tracecontext.ContextWithSpan(ctx, span)
+	}
+
+	var group *log.GroupResult
+	if threadId != "" {
+		group = claims.JoinGroup(threadId, r.Method, " ", route)
+	} else {
+		group = claims.Group(r.Method, " ", route)
+	}
+	// Close has to be deferred directly for it to log panics from the handler.
+	defer group.Close(summary)
+	// Runs before Close. Claims are read by Close so the response details are part of the closing entry.
+	completed := false
+	defer func() {
+		if !completed && !recorder.wroteHeader {
+			// The handler panicked before writing a response
+			recorder.status = http.StatusInternalServerError
+		}
+		summary.latency = time.Since(start)
+		claims["status"] = recorder.statusCode()
+		claims["bytes"] = recorder.bytes
+		claims["latency_ms"] = float64(summary.latency) / float64(time.Millisecond)
+	}()
+
+	ctx = context.WithValue(ctx, contextKey{}, group)
+	m.next.ServeHTTP(recorder, r.WithContext(ctx))
+	completed = true
+}
+
+// requestSummary is formatted when the group is closed, after the handler is done.
+type requestSummary struct {
+	method   string
+	route    string
+	recorder *responseRecorder
+	latency  time.Duration
+}
+
+func (summary *requestSummary) String() string {
+	return fmt.Sprintf("%s %s %d %s", summary.method, summary.route, summary.recorder.statusCode(), summary.latency)
+}
+
+// responseRecorder captures the status code and number of bytes written by a handler.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (recorder *responseRecorder) statusCode() int {
+	if recorder.status == 0 {
+		return http.StatusOK
+	}
+	return recorder.status
+}
+
+func (recorder *responseRecorder) WriteHeader(status int) {
+	if !recorder.wroteHeader {
+		recorder.status = status
+		recorder.wroteHeader = true
+	}
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *responseRecorder) Write(p []byte) (int, error) {
+	if !recorder.wroteHeader {
+		recorder.WriteHeader(http.StatusOK)
+	}
+	n, err := recorder.ResponseWriter.Write(p)
+	recorder.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if supported by the underlying writer.
+func (recorder *responseRecorder) Flush() {
+	if flusher, ok := recorder.ResponseWriter.(http.Flusher); ok {
+		if !recorder.wroteHeader {
+			recorder.WriteHeader(http.StatusOK)
+		}
+		flusher.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if supported by the underlying writer.
+func (recorder *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := recorder.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("alt4http: underlying ResponseWriter doesn't implement http.Hijacker")
+	}
+	if !recorder.wroteHeader {
+		recorder.status = http.StatusSwitchingProtocols
+		recorder.wroteHeader = true
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the underlying writer. Used by http.ResponseController.
+func (recorder *responseRecorder) Unwrap() http.ResponseWriter {
+	return recorder.ResponseWriter
+}
@@ -0,0 +1,103 @@
+package alt4http
+
+import (
+	"github.com/alt4dev/go/alt4test"
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/protobuff/proto"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// split separates the group entry from the rest. Writes are asynchronous so entries can arrive in any order.
+func split(logs []*proto.Log) (group *proto.Log, rest []*proto.Log) {
+	for _, msg := range logs {
+		if msg.Group {
+			group = msg
+		} else {
+			rest = append(rest, msg)
+		}
+	}
+	return
+}
+
+func TestMiddleware(t *testing.T) {
+	helper := alt4test.Record()
+	var requestGroup *log.GroupResult
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestGroup = GroupFromContext(r.Context())
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+	}))
+
+	request := httptest.NewRequest("POST", "/users", nil)
+	request.Header.Set("X-Request-Id", "request-1")
+	request.Header.Set("User-Agent", "tester")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	if requestGroup == nil {
+		t.Error("Expected the group to be available from the request context")
+	}
+	group, logs := split(helper.Entries())
+	if group == nil || len(logs) != 1 {
+		t.Fatalf("Expected a group entry and a closing entry. Found %v", helper.Entries())
+	}
+	if group.Message != "POST /users" {
+		t.Errorf("Unexpected group entry. %v", group)
+	}
+	if group.Thread != logs[0].Thread {
+		t.Error("Expected the closing entry to be part of the request group")
+	}
+	expected := map[string]string{
+		"method":     "POST",
+		"route":      "/users",
+		"user_agent": "tester",
+		"request_id": "request-1",
+		"status":     "201",
+		"bytes":      "5",
+	}
+	for name, value := range expected {
+		if found := alt4test.ClaimValue(logs[0], name); found != value {
+			t.Errorf("Unexpected value for claim `%s`. '%s' != '%s'", name, found, value)
+		}
+	}
+	if alt4test.Claim(logs[0], "latency_ms") == nil {
+		t.Error("Expected latency to be recorded on close")
+	}
+}
+
+func TestMiddlewarePanic(t *testing.T) {
+	helper := alt4test.Record()
+	handler := NewMiddleware(Options{
+		Route: func(r *http.Request) string {
+			return "/panic/{id}"
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("handler failed")
+	}))
+
+	func() {
+		defer func() {
+			if r := recover(); r != "handler failed" {
+				t.Errorf("Expected the panic to be propagated. Found: %v", r)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic/1", nil))
+	}()
+
+	var fatal *proto.Log
+	for _, msg := range helper.Entries() {
+		if msg.Level == proto.Log_FATAL {
+			fatal = msg
+		}
+	}
+	if fatal == nil || fatal.Message != "handler failed" {
+		t.Fatalf("Expected the panic to be logged. Found %v", helper.Entries())
+	}
+	if status := alt4test.ClaimValue(fatal, "status"); status != "500" {
+		t.Errorf("Expected status 500 for a panicking handler. Found: %s", status)
+	}
+	if route := alt4test.ClaimValue(fatal, "route"); route != "/panic/{id}" {
+		t.Errorf("Unexpected route. %s", route)
+	}
+}
@@ -0,0 +1,184 @@
+package alt4http
+
+import (
+	"context"
+	"fmt"
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/go/tracecontext"
+	"github.com/alt4dev/protobuff/proto"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ThreadHeader is the header used to propagate the thread id of a log group between services.
+// The middleware continues the thread found in this header, if it trusts it, so that logs from the caller and the callee are grouped together.
+const ThreadHeader = "Alt4-Thread"
+
+// RedactedValue replaces the values of redacted query parameters.
+const RedactedValue = "REDACTED"
+
+// RedactedPassword replaces the password of URLs with credentials in the logged URL.
+const RedactedPassword = "xxxxx"
+
+// TransportOptions configure the RoundTripper returned by NewTransport.
+type TransportOptions struct {
+	// RedactQuery lists query parameters whose values are replaced by `REDACTED` in the logged URL.
+	RedactQuery []string
+	// RedactAllQuery replaces the values of all query parameters in the logged URL.
+	RedactAllQuery bool
+	// Levels maps a status class i.e. status/100 to the level used to log the response.
+	// Classes not found default to INFO for 1xx-3xx, WARNING for 4xx and ERROR for 5xx.
+	Levels map[int]proto.Log_Level
+	// ErrorLevel is the level used to log requests that fail without a response. Defaults to ERROR.
+	ErrorLevel proto.Log_Level
+	// PropagateHosts the hosts the thread id of the current group is sent to, in the `Alt4-Thread` header and with TraceContext the `traceparent` header.
+	// Hosts starting with a `.` match subdomains e.g. `.internal.example.com`. The thread id isn't sent to other hosts.
+	PropagateHosts []string
+	// TraceContext enables W3C Trace Context. A `traceparent` header is sent to PropagateHosts with the thread id of the current group
+	// as the trace id, unless the request already has one. The trace id, span id and parent span id are recorded as claims.
+	TraceContext bool
+}
+
+var defaultLevels = map[int]proto.Log_Level{
+	1: proto.Log_INFO,
+	2: proto.Log_INFO,
+	3: proto.Log_INFO,
+	4: proto.Log_WARNING,
+	5: proto.Log_ERROR,
+}
+
+type attemptKey struct{}
+
+// WithRetryAttempt annotates a request context with the attempt number of a retried request.
+// The transport logs the attempt as a claim. Use it from retrying clients e.g. `req.WithContext(WithRetryAttempt(ctx, 2))`
+func WithRetryAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, attempt)
+}
+
+// Transport wraps base with a RoundTripper that logs requests using the default options.
+// If base is nil, http.DefaultTransport is used.
+// Example: client := &http.Client{Transport: alt4http.Transport(nil)}
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return NewTransport(base, TransportOptions{})
+}
+
+// NewTransport wraps base with a RoundTripper that logs every outbound request and its response.
+// Entries are written within the group of the goroutine making the request.
+// If base is nil, http.DefaultTransport is used.
+func NewTransport(base http.RoundTripper, opts TransportOptions) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if opts.ErrorLevel == proto.Log_NONE {
+		opts.ErrorLevel = proto.Log_ERROR
+	}
+	redact := make(map[string]bool, len(opts.RedactQuery))
+	for _, key := range opts.RedactQuery {
+		redact[key] = true
+	}
+	return &transport{base: base, opts: opts, redact: redact}
+}
+
+type transport struct {
+	base   http.RoundTripper
+	opts   TransportOptions
+	redact map[string]bool
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper should not modify the request it receives
+	cloned := false
+	setHeader := func(key, value string) {
+		if !cloned {
+			req = req.Clone(req.Context())
+			cloned = true
+		}
+		req.Header.Set(key, value)
+	}
+	threadId, grouped := service.ThreadId()
+	propagate := t.propagates(req.URL.Hostname())
+	if grouped && propagate {
+		setHeader(ThreadHeader, threadId)
+	}
+	var traceClaims log.Claims
+	if t.opts.TraceContext {
+		span, err := tracecontext.Parse(req.Header.Get(tracecontext.Header))
+		if err != nil {
+			span = tracecontext.Outgoing(req.Context(), threadId)
+			if propagate {
+				setHeader(tracecontext.Header, span.Traceparent())
+			}
+		}
+		traceClaims = span.Claims()
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	duration := time.Since(start)
+
+	requestUrl := t.redactUrl(req.URL)
+	claims := log.Claims{
+		"method":      req.Method,
+		"url":         requestUrl,
+		"duration_ms": float64(duration) / float64(time.Millisecond),
+	}
+	for key, value := range traceClaims {
+		claims[key] = value
+	}
+	if attempt, ok := req.Context().Value(attemptKey{}).(int); ok {
+		claims["attempt"] = attempt
+	}
+	if err != nil {
+		claims["error"] = err.Error()
+		claims.Log(t.opts.ErrorLevel, fmt.Sprintf("%s %s failed after %s: %s", req.Method, requestUrl, duration, err))
+		return resp, err
+	}
+	claims["status"] = resp.StatusCode
+	claims.Log(t.level(resp.StatusCode), fmt.Sprintf("%s %s %s (%s)", req.Method, requestUrl, resp.Status, duration))
+	return resp, nil
+}
+
+func (t *transport) level(status int) proto.Log_Level {
+	if level, ok := t.opts.Levels[status/100]; ok {
+		return level
+	}
+	if level, ok := defaultLevels[status/100]; ok {
+		return level
+	}
+	return proto.Log_INFO
+}
+
+// propagates reports whether the thread id is sent to host.
+func (t *transport) propagates(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range t.opts.PropagateHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, ".") && (strings.HasSuffix(host, allowed) || host == allowed[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *transport) redactUrl(u *url.URL) string {
+	redacted := *u
+	if _, hasPassword := u.User.Password(); hasPassword {
+		redacted.User = url.UserPassword(u.User.Username(), RedactedPassword)
+	}
+	if u.RawQuery == "" || (!t.opts.RedactAllQuery && len(t.redact) == 0) {
+		return redacted.String()
+	}
+	query := u.Query()
+	for key, values := range query {
+		if t.opts.RedactAllQuery || t.redact[key] {
+			for i := range values {
+				values[i] = RedactedValue
+			}
+		}
+	}
+	redacted.RawQuery = query.Encode()
+	return redacted.String()
+}
@@ -0,0 +1,84 @@
+// Package alt4logrus provides a logrus hook that forwards entries to alt4.
+package alt4logrus
+
+import (
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// HookOptions configure a Hook.
+type HookOptions struct {
+	// Levels the levels forwarded to alt4. Defaults to logrus.AllLevels.
+	Levels []logrus.Level
+	// SyncFatal waits for Fatal and Panic entries to be acknowledged by alt4 before logrus exits or panics.
+	SyncFatal bool
+	// ClaimProviders add claims to every entry forwarded by the hook. They're called with the context of the entry e.g. from logrus.WithContext
+	ClaimProviders []service.ClaimProvider
+}
+
+// Hook is a logrus.Hook that forwards entries to alt4.
+// Entries are written within the group of the goroutine that logs them.
+type Hook struct {
+	levels    []logrus.Level
+	syncFatal bool
+	providers []service.ClaimProvider
+}
+
+// NewHook creates a hook.
+// Example: logrus.AddHook(alt4logrus.NewHook(alt4logrus.HookOptions{SyncFatal: true}))
+func NewHook(opts HookOptions) *Hook {
+	levels := opts.Levels
+	if levels == nil {
+		levels = logrus.AllLevels
+	}
+	return &Hook{levels: levels, syncFatal: opts.SyncFatal, providers: opts.ClaimProviders}
+}
+
+// Level maps a logrus level to an alt4 log level. Trace maps to DEBUG while Fatal and Panic map to FATAL.
+func Level(level logrus.Level) proto.Log_Level {
+	switch level {
+	case logrus.TraceLevel, logrus.DebugLevel:
+		return proto.Log_DEBUG
+	case logrus.InfoLevel:
+		return proto.Log_INFO
+	case logrus.WarnLevel:
+		return proto.Log_WARNING
+	case logrus.ErrorLevel:
+		return proto.Log_ERROR
+	default:
+		return proto.Log_FATAL
+	}
+}
+
+// Levels returns the levels forwarded by the hook.
+func (hook *Hook) Levels() []logrus.Level {
+	return hook.levels
+}
+
+// Fire forwards an entry to alt4. Fields are converted to claims.
+// The file, line and function are recorded when the logger has ReportCaller enabled.
+func (hook *Hook) Fire(entry *logrus.Entry) error {
+	claims := make(log.Claims, len(entry.Data))
+	for key, value := range entry.Data {
+		claims[key] = value
+	}
+
+	var file, function string
+	var line int
+	if entry.HasCaller() {
+		file, line, function = entry.Caller.File, entry.Caller.Line, entry.Caller.Function
+	}
+	logTime := entry.Time
+	if logTime.IsZero() {
+		logTime = service.LogTime()
+	}
+	result := service.LogCallerContext(entry.Context, hook.providers, file, line, function, false, entry.Message, claims.ProtoClaims(), Level(entry.Level), logTime)
+
+	if hook.syncFatal && entry.Level <= logrus.FatalLevel {
+		_, err := result.Result()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package alt4logrus
+
+import (
+	"errors"
+	"github.com/alt4dev/go/alt4test"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"github.com/sirupsen/logrus"
+	"io/ioutil"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func whereAmI() int {
+	_, _, l, _ := runtime.Caller(1)
+	return l
+}
+
+func TestHook(t *testing.T) {
+	helper := alt4test.Record()
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Level = logrus.TraceLevel
+	logger.ReportCaller = true
+	logger.AddHook(NewHook(HookOptions{}))
+
+	line := whereAmI() + 1
+	logger.WithFields(logrus.Fields{"order_id": 10, "at": time.Unix(10, 0)}).WithError(errors.New("timeout")).Trace("order failed")
+	service.WaitGroup().Wait()
+
+	logs := helper.Entries()
+	if len(logs) != 1 {
+		t.Fatalf("Expected a single entry. Found %v", logs)
+	}
+	msg := logs[0]
+	if msg.Message != "order failed" || msg.Level != proto.Log_DEBUG {
+		t.Errorf("Unexpected entry %v", msg)
+	}
+	_, file, _, _ := runtime.Caller(0)
+	if msg.File != file || msg.Line != uint32(line) || msg.Function != "github.com/alt4dev/go/alt4logrus.TestHook" {
+		t.Errorf("Expected logrus' caller to be used. %s:%d %s", msg.File, msg.Line, msg.Function)
+	}
+	type claim struct {
+		Type  proto.Claim_Type
+		Value string
+	}
+	expected := map[string]claim{
+		"order_id": {proto.Claim_NUMBER, "10"},
+		"at":       {proto.Claim_TIMESTAMP, "10000000000"},
+		"error":    {proto.Claim_STRING, "timeout"},
+	}
+	for name, value := range expected {
+		found := alt4test.Claim(msg, name)
+		if found == nil || found.Type != value.Type || found.Value != value.Value {
+			t.Errorf("Unexpected claim `%s`. %v", name, found)
+		}
+	}
+}
+
+func TestHookSyncFatal(t *testing.T) {
+	helper := alt4test.Record()
+	helper.Delay = 20 * time.Millisecond
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.AddHook(NewHook(HookOptions{SyncFatal: true}))
+	exited := false
+	logger.ExitFunc = func(code int) {
+		exited = true
+		// The entry should be written before logrus exits
+		if logs := helper.Entries(); len(logs) != 1 || logs[0].Level != proto.Log_FATAL {
+			t.Errorf("Expected the fatal entry to be written before exiting. Found %v", logs)
+		}
+	}
+	logger.Fatal("shutting down")
+	if !exited {
+		t.Error("Expected logrus to exit")
+	}
+}
+
+func TestLevel(t *testing.T) {
+	levels := map[logrus.Level]proto.Log_Level{
+		logrus.TraceLevel: proto.Log_DEBUG,
+		logrus.DebugLevel: proto.Log_DEBUG,
+		logrus.InfoLevel:  proto.Log_INFO,
+		logrus.WarnLevel:  proto.Log_WARNING,
+		logrus.ErrorLevel: proto.Log_ERROR,
+		logrus.FatalLevel: proto.Log_FATAL,
+		logrus.PanicLevel: proto.Log_FATAL,
+	}
+	for level, expected := range levels {
+		if Level(level) != expected {
+			t.Errorf("Unexpected level for %s. %s != %s", level, Level(level), expected)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+//go:build go1.21
+// +build go1.21
+
+// Package alt4slog provides a log/slog handler that writes records to alt4.
+package alt4slog
+
+import (
+	"context"
+	"fmt"
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"log/slog"
+	"math"
+	"runtime"
+	"strconv"
+)
+
+// HandlerOptions configure a Handler.
+type HandlerOptions struct {
+	// Level reports the minimum level of records that are written. Defaults to slog.LevelInfo.
+	Level slog.Leveler
+	// FatalLevel the minimum level of records that are written as FATAL e.g. slog.LevelError+4.
+	// Records are never written as FATAL if it's not set.
+	FatalLevel slog.Leveler
+	// ClaimProviders add claims to every record of the handler. They're called with the context passed to the logger e.g. by slog.InfoContext
+	ClaimProviders []service.ClaimProvider
+}
+
+// Handler is a slog.Handler that writes records to alt4.
+// Records are written within the group of the goroutine that logs them.
+type Handler struct {
+	level     slog.Leveler
+	fatal     slog.Leveler
+	providers []service.ClaimProvider
+	claims    []*proto.Claim
+	prefix    string
+}
+
+// NewHandler creates a handler. opts can be nil to use the default options.
+// Example: slog.SetDefault(slog.New(alt4slog.NewHandler(nil)))
+func NewHandler(opts *HandlerOptions) *Handler {
+	handler := &Handler{level: slog.LevelInfo}
+	if opts != nil && opts.Level != nil {
+		handler.level = opts.Level
+	}
+	if opts != nil {
+		handler.fatal = opts.FatalLevel
+		handler.providers = opts.ClaimProviders
+	}
+	return handler
+}
+
+// Level maps a slog level to an alt4 log level.
+// Levels above slog.LevelError e.g. slog.LevelError+4 map to ERROR. See HandlerOptions.FatalLevel to write records as FATAL.
+func Level(level slog.Level) proto.Log_Level {
+	switch {
+	case level < slog.LevelInfo:
+		return proto.Log_DEBUG
+	case level < slog.LevelWarn:
+		return proto.Log_INFO
+	case level < slog.LevelError:
+		return proto.Log_WARNING
+	default:
+		return proto.Log_ERROR
+	}
+}
+
+// levelOf maps the level of a record applying the FATAL threshold of the handler.
+func (handler *Handler) levelOf(level slog.Level) proto.Log_Level {
+	if handler.fatal != nil && level >= handler.fatal.Level() {
+		return proto.Log_FATAL
+	}
+	return Level(level)
+}
+
+// Enabled reports whether the handler writes records at level.
+func (handler *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= handler.level.Level()
+}
+
+// Handle writes a record to alt4. The file, line and function are read from the PC of the record.
+// Claim providers are called with ctx.
+func (handler *Handler) Handle(ctx context.Context, record slog.Record) error {
+	claims := make([]*proto.Claim, len(handler.claims), len(handler.claims)+record.NumAttrs())
+	copy(claims, handler.claims)
+	record.Attrs(func(attr slog.Attr) bool {
+		claims = appendAttr(claims, handler.prefix, attr)
+		return true
+	})
+
+	var file, function string
+	var line int
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		file, line, function = frame.File, frame.Line, frame.Function
+	}
+	logTime := record.Time
+	if logTime.IsZero() {
+		logTime = service.LogTime()
+	}
+	service.LogCallerContext(ctx, handler.providers, file, line, function, false, record.Message, claims, handler.levelOf(record.Level), logTime)
+	return nil
+}
+
+// WithAttrs returns a handler whose records include attrs. The claims for attrs are computed once.
+func (handler *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return handler
+	}
+	claims := make([]*proto.Claim, len(handler.claims), len(handler.claims)+len(attrs))
+	copy(claims, handler.claims)
+	for _, attr := range attrs {
+		claims = appendAttr(claims, handler.prefix, attr)
+	}
+	return &Handler{level: handler.level, fatal: handler.fatal, providers: handler.providers, claims: claims, prefix: handler.prefix}
+}
+
+// WithGroup returns a handler that qualifies the names of subsequent attributes with name e.g. `name.key`
+func (handler *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return handler
+	}
+	return &Handler{level: handler.level, fatal: handler.fatal, providers: handler.providers, claims: handler.claims, prefix: handler.prefix + name + "."}
+}
+
+// appendAttr converts an attribute to claims. Groups are flattened into dotted claim names.
+func appendAttr(claims []*proto.Claim, prefix string, attr slog.Attr) []*proto.Claim {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return claims
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix = prefix + attr.Key + "."
+		}
+		for _, groupAttr := range attr.Value.Group() {
+			claims = appendAttr(claims, groupPrefix, groupAttr)
+		}
+		return claims
+	}
+	claimType, claimValue := claimOf(attr.Value)
+	claims = append(claims, &proto.Claim{
+		Name:  prefix + attr.Key,
+		Type:  claimType,
+		Value: claimValue,
+	})
+	if attr.Value.Kind() == slog.KindDuration {
+		// The same way as log.Duration
+		claims = append(claims, &proto.Claim{Name: prefix + attr.Key + ".unit", Type: proto.Claim_STRING, Value: log.DurationUnit})
+	}
+	return claims
+}
+
+func claimOf(value slog.Value) (proto.Claim_Type, string) {
+	switch value.Kind() {
+	case slog.KindString:
+		return proto.Claim_STRING, value.String()
+	case slog.KindInt64:
+		return proto.Claim_NUMBER, strconv.FormatInt(value.Int64(), 10)
+	case slog.KindUint64:
+		return proto.Claim_NUMBER, strconv.FormatUint(value.Uint64(), 10)
+	case slog.KindFloat64:
+		// NaN and infinities aren't numbers alt4 can query, like log.Float64 they're written as strings
+		if f := value.Float64(); math.IsNaN(f) || math.IsInf(f, 0) {
+			return proto.Claim_STRING, strconv.FormatFloat(f, 'g', -1, 64)
+		}
+		return proto.Claim_NUMBER, strconv.FormatFloat(value.Float64(), 'g', -1, 64)
+	case slog.KindBool:
+		return proto.Claim_BOOLEAN, strconv.FormatBool(value.Bool())
+	case slog.KindDuration:
+		// Like slog.JSONHandler and log.Duration durations are recorded in nanoseconds
+		return proto.Claim_NUMBER, strconv.FormatInt(int64(value.Duration()), 10)
+	case slog.KindTime:
+		return proto.Claim_TIMESTAMP, strconv.FormatInt(value.Time().UnixNano(), 10)
+	}
+	if err, ok := value.Any().(error); ok {
+		return proto.Claim_STRING, err.Error()
+	}
+	return proto.Claim_STRING, fmt.Sprint(value.Any())
+}
@@ -0,0 +1,117 @@
+//go:build go1.21
+// +build go1.21
+
+package alt4slog
+
+import (
+	"context"
+	"errors"
+	"github.com/alt4dev/go/alt4test"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"log/slog"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func whereAmI() int {
+	_, _, l, _ := runtime.Caller(1)
+	return l
+}
+
+func TestHandler(t *testing.T) {
+	helper := alt4test.Record()
+	logger := slog.New(NewHandler(&HandlerOptions{Level: slog.LevelDebug})).
+		With("service", "api").
+		WithGroup("request").
+		With(slog.Int("size", 10))
+
+	line := whereAmI() + 1
+	logger.Warn("slow request", "took", 2*time.Second, slog.Group("user", "id", 7, "admin", true), "err", errors.New("timeout"), "ratio", math.NaN(), "limit", math.Inf(1))
+	service.WaitGroup().Wait()
+
+	if len(helper.Entries()) != 1 {
+		t.Fatalf("Expected a single entry. Found %v", helper.Entries())
+	}
+	msg := helper.Entries()[0]
+	if msg.Message != "slow request" || msg.Level != proto.Log_WARNING {
+		t.Errorf("Unexpected entry %v", msg)
+	}
+	_, file, _, _ := runtime.Caller(0)
+	if msg.File != file || msg.Line != uint32(line) || msg.Function != "github.com/alt4dev/go/alt4slog.TestHandler" {
+		t.Errorf("Expected the caller to be read from the record. %s:%d %s", msg.File, msg.Line, msg.Function)
+	}
+	type claim struct {
+		Type  proto.Claim_Type
+		Value string
+	}
+	expected := map[string]claim{
+		"service":            {Type: proto.Claim_STRING, Value: "api"},
+		"request.size":       {Type: proto.Claim_NUMBER, Value: "10"},
+		"request.took":       {Type: proto.Claim_NUMBER, Value: "2000000000"},
+		"request.took.unit":  {Type: proto.Claim_STRING, Value: "ns"},
+		"request.ratio":      {Type: proto.Claim_STRING, Value: "NaN"},
+		"request.limit":      {Type: proto.Claim_STRING, Value: "+Inf"},
+		"request.user.id":    {Type: proto.Claim_NUMBER, Value: "7"},
+		"request.user.admin": {Type: proto.Claim_BOOLEAN, Value: "true"},
+		"request.err":        {Type: proto.Claim_STRING, Value: "timeout"},
+	}
+	if len(msg.Claims) != len(expected) {
+		t.Errorf("Unexpected claims %v", msg.Claims)
+	}
+	for name, value := range expected {
+		found := alt4test.Claim(msg, name)
+		if found == nil || found.Type != value.Type || found.Value != value.Value {
+			t.Errorf("Unexpected claim `%s`. %v", name, found)
+		}
+	}
+}
+
+type tenantKey struct{}
+
+func TestHandlerClaimProviders(t *testing.T) {
+	helper := alt4test.Record()
+	tenant := func(entry service.Entry) []*proto.Claim {
+		return []*proto.Claim{{Name: "tenant", Type: proto.Claim_STRING, Value: entry.Context.Value(tenantKey{}).(string)}}
+	}
+	logger := slog.New(NewHandler(&HandlerOptions{ClaimProviders: []service.ClaimProvider{tenant}})).With("service", "api")
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	logger.InfoContext(ctx, "request")
+	service.WaitGroup().Wait()
+	if len(helper.Entries()) != 1 {
+		t.Fatalf("Expected a single entry. Found %v", helper.Entries())
+	}
+	found := alt4test.Claim(helper.Entries()[0], "tenant")
+	if found == nil || found.Value != "acme" || alt4test.Claim(helper.Entries()[0], "service") == nil {
+		t.Errorf("Expected the claims of the provider to be added. %v", helper.Entries()[0].Claims)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	levels := map[slog.Level]proto.Log_Level{
+		slog.LevelDebug:     proto.Log_DEBUG,
+		slog.LevelInfo:      proto.Log_INFO,
+		slog.LevelWarn:      proto.Log_WARNING,
+		slog.LevelError:     proto.Log_ERROR,
+		slog.LevelError + 2: proto.Log_ERROR,
+		slog.LevelError + 4: proto.Log_ERROR,
+	}
+	for level, expected := range levels {
+		if Level(level) != expected {
+			t.Errorf("Unexpected level for %s. %s != %s", level, Level(level), expected)
+		}
+	}
+
+	handler := NewHandler(&HandlerOptions{FatalLevel: slog.LevelError + 4})
+	if handler.levelOf(slog.LevelError+2) != proto.Log_ERROR || handler.levelOf(slog.LevelError+4) != proto.Log_FATAL {
+		t.Error("Expected records at or above FatalLevel to be FATAL")
+	}
+
+	handler = NewHandler(nil)
+	if handler.Enabled(context.Background(), slog.LevelDebug) || !handler.Enabled(context.Background(), slog.LevelInfo) {
+		t.Error("Expected the handler to default to slog.LevelInfo")
+	}
+}
@@ -0,0 +1,253 @@
+package alt4sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"time"
+)
+
+// conn logs the operations of a driver connection.
+// Optional interfaces not implemented by the wrapped connection return driver.ErrSkip so database/sql falls back
+// to the interfaces that are implemented.
+type conn struct {
+	conn driver.Conn
+	opts Options
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	s, err := c.conn.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{stmt: s, conn: c.conn, query: query, opts: c.opts}, nil
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var s driver.Stmt
+	var err error
+	if preparer, ok := c.conn.(driver.ConnPrepareContext); ok {
+		s, err = preparer.PrepareContext(ctx, query)
+	} else {
+		s, err = c.conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &stmt{stmt: s, conn: c.conn, query: query, opts: c.opts}, nil
+}
+
+func (c *conn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	start := time.Now()
+	t, err := c.conn.Begin()
+	c.opts.logOperation("begin", "", nil, start, nil, err)
+	if err != nil {
+		return nil, err
+	}
+	return &tx{tx: t, opts: c.opts}, nil
+}
+
+func (c *conn) BeginTx(ctx context.Context, txOpts driver.TxOptions) (driver.Tx, error) {
+	beginner, ok := c.conn.(driver.ConnBeginTx)
+	if !ok {
+		// Like database/sql, options that can't be passed to Begin are rejected rather than ignored
+		if txOpts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+			return nil, errIsolationLevel
+		}
+		if txOpts.ReadOnly {
+			return nil, errReadOnly
+		}
+		return c.Begin()
+	}
+	start := time.Now()
+	t, err := beginner.BeginTx(ctx, txOpts)
+	c.opts.logOperation("begin", "", nil, start, nil, err)
+	if err != nil {
+		return nil, err
+	}
+	return &tx{tx: t, opts: c.opts}, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var result driver.Result
+	var err error
+	if execer, ok := c.conn.(driver.ExecerContext); ok {
+		result, err = execer.ExecContext(ctx, query, args)
+	} else if execer, ok := c.conn.(driver.Execer); ok {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			result, err = execer.Exec(query, values)
+		}
+	} else {
+		return nil, driver.ErrSkip
+	}
+	c.opts.logOperation("exec", query, args, start, result, err)
+	return result, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if queryer, ok := c.conn.(driver.QueryerContext); ok {
+		rows, err = queryer.QueryContext(ctx, query, args)
+	} else if queryer, ok := c.conn.(driver.Queryer); ok {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			rows, err = queryer.Query(query, values)
+		}
+	} else {
+		return nil, driver.ErrSkip
+	}
+	c.opts.logOperation("query", query, args, start, nil, err)
+	return rows, err
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if pinger, ok := c.conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := c.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	// Use the default conversion
+	return driver.ErrSkip
+}
+
+// tx logs commits and rollbacks of a transaction.
+type tx struct {
+	tx   driver.Tx
+	opts Options
+}
+
+func (t *tx) Commit() error {
+	start := time.Now()
+	err := t.tx.Commit()
+	t.opts.logOperation("commit", "", nil, start, nil, err)
+	return err
+}
+
+func (t *tx) Rollback() error {
+	start := time.Now()
+	err := t.tx.Rollback()
+	t.opts.logOperation("rollback", "", nil, start, nil, err)
+	return err
+}
+
+// stmt logs the execution of a prepared statement.
+type stmt struct {
+	stmt driver.Stmt
+	// conn the connection the statement was prepared on. Its NamedValueChecker is used if the statement doesn't have one.
+	conn  driver.Conn
+	query string
+	opts  Options
+}
+
+func (s *stmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *stmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *stmt) Exec(values []driver.Value) (driver.Result, error) {
+	start := time.Now()
+	result, err := s.stmt.Exec(values)
+	s.opts.logOperation("exec", s.query, valuesToNamedValues(values), start, result, err)
+	return result, err
+}
+
+func (s *stmt) Query(values []driver.Value) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.stmt.Query(values)
+	s.opts.logOperation("query", s.query, valuesToNamedValues(values), start, nil, err)
+	return rows, err
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var result driver.Result
+	var err error
+	if execer, ok := s.stmt.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			result, err = s.stmt.Exec(values)
+		}
+	}
+	s.opts.logOperation("exec", s.query, args, start, result, err)
+	return result, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			rows, err = s.stmt.Query(values)
+		}
+	}
+	s.opts.logOperation("query", s.query, args, start, nil, err)
+	return rows, err
+}
+
+// CheckNamedValue checks arguments the same way database/sql does for the wrapped driver i.e. with the statement's checker
+// or else the connection's.
+func (s *stmt) CheckNamedValue(value *driver.NamedValue) error {
+	if checker, ok := s.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	if checker, ok := s.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(value)
+	}
+	return driver.ErrSkip
+}
+
+// ColumnConverter returns the converter of the wrapped statement or the default conversion.
+func (s *stmt) ColumnConverter(idx int) driver.ValueConverter {
+	if converter, ok := s.stmt.(driver.ColumnConverter); ok {
+		return converter.ColumnConverter(idx)
+	}
+	return driver.DefaultParameterConverter
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errNamedArgs
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
+
+func valuesToNamedValues(values []driver.Value) []driver.NamedValue {
+	args := make([]driver.NamedValue, len(values))
+	for i, value := range values {
+		args[i] = driver.NamedValue{Ordinal: i + 1, Value: value}
+	}
+	return args
+}
@@ -0,0 +1,180 @@
+// Package alt4sql wraps database/sql drivers to log queries, statements and transactions to alt4.
+// Entries are written within the group of the goroutine running the query.
+package alt4sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/protobuff/proto"
+	"time"
+)
+
+var errNamedArgs = errors.New("alt4sql: driver does not support the use of named parameters")
+var errIsolationLevel = errors.New("alt4sql: driver does not support non-default isolation level")
+var errReadOnly = errors.New("alt4sql: driver does not support read-only transactions")
+
+// Options configure the logging of a wrapped driver.
+type Options struct {
+	// RedactArgs stops argument values from being logged. The number of arguments is always logged.
+	RedactArgs bool
+	// SlowThreshold escalates the level of operations taking longer than the threshold to WARNING. Zero disables this.
+	SlowThreshold time.Duration
+	// Level the level used to log successful operations. Defaults to DEBUG.
+	Level proto.Log_Level
+	// ErrorLevel the level used to log failed operations. Defaults to ERROR.
+	ErrorLevel proto.Log_Level
+}
+
+func (opts Options) withDefaults() Options {
+	if opts.Level == proto.Log_NONE {
+		opts.Level = proto.Log_DEBUG
+	}
+	if opts.ErrorLevel == proto.Log_NONE {
+		opts.ErrorLevel = proto.Log_ERROR
+	}
+	return opts
+}
+
+// Wrap returns a driver that logs the operations of d.
+// Example: sql.Register("alt4-postgres", alt4sql.Wrap(&pq.Driver{}, alt4sql.Options{}))
+func Wrap(d driver.Driver, opts Options) driver.Driver {
+	return &wrappedDriver{driver: d, opts: opts.withDefaults()}
+}
+
+// WrapConnector returns a connector that logs the operations of connections opened by c.
+// Example: db := sql.OpenDB(alt4sql.WrapConnector(connector, alt4sql.Options{}))
+func WrapConnector(c driver.Connector, opts Options) driver.Connector {
+	opts = opts.withDefaults()
+	return &wrappedConnector{connector: c, driver: &wrappedDriver{driver: c.Driver(), opts: opts}, opts: opts}
+}
+
+// Open opens a database using the registered driver `driverName` wrapped to log its operations.
+func Open(driverName, dataSourceName string, opts Options) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	_ = db.Close()
+	if driverContext, ok := d.(driver.DriverContext); ok {
+		connector, err := driverContext.OpenConnector(dataSourceName)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(WrapConnector(connector, opts)), nil
+	}
+	return sql.OpenDB(&dsnConnector{dataSourceName: dataSourceName, driver: Wrap(d, opts)}), nil
+}
+
+type wrappedDriver struct {
+	driver driver.Driver
+	opts   Options
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	c, err := d.driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{conn: c, opts: d.opts}, nil
+}
+
+func (d *wrappedDriver) OpenConnector(name string) (driver.Connector, error) {
+	if driverContext, ok := d.driver.(driver.DriverContext); ok {
+		connector, err := driverContext.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return &wrappedConnector{connector: connector, driver: d, opts: d.opts}, nil
+	}
+	return &dsnConnector{dataSourceName: name, driver: d}, nil
+}
+
+type wrappedConnector struct {
+	connector driver.Connector
+	driver    driver.Driver
+	opts      Options
+}
+
+func (c *wrappedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dc, err := c.connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{conn: dc, opts: c.opts}, nil
+}
+
+func (c *wrappedConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// dsnConnector is used for drivers that don't implement driver.DriverContext
+type dsnConnector struct {
+	dataSourceName string
+	driver         driver.Driver
+}
+
+func (c *dsnConnector) Connect(_ context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dataSourceName)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// logOperation logs a database operation. Operations skipped by the driver i.e. driver.ErrSkip aren't logged.
+func (opts Options) logOperation(operation string, statement string, args []driver.NamedValue, start time.Time, result driver.Result, err error) {
+	if err == driver.ErrSkip {
+		return
+	}
+	duration := time.Since(start)
+	claims := log.Claims{
+		"operation":   operation,
+		"duration_ms": float64(duration) / float64(time.Millisecond),
+	}
+	if statement != "" {
+		claims["statement"] = statement
+	}
+	if args != nil {
+		claims["args"] = len(args)
+		if !opts.RedactArgs {
+			for _, arg := range args {
+				name := arg.Name
+				if name == "" {
+					name = fmt.Sprint(arg.Ordinal)
+				}
+				value := arg.Value
+				if b, ok := value.([]byte); ok {
+					value = string(b)
+				}
+				claims["arg."+name] = value
+			}
+		}
+	}
+	if result != nil && err == nil {
+		if rows, rowsErr := result.RowsAffected(); rowsErr == nil {
+			claims["rows_affected"] = rows
+		}
+	}
+
+	level := opts.Level
+	slow := opts.SlowThreshold > 0 && duration >= opts.SlowThreshold
+	if slow {
+		level = proto.Log_WARNING
+		claims["slow"] = true
+	}
+	message := fmt.Sprintf("sql %s (%s)", operation, duration)
+	if statement != "" {
+		message = fmt.Sprintf("%s: %s", message, statement)
+	}
+	if err != nil {
+		level = opts.ErrorLevel
+		claims["error"] = err.Error()
+		message = fmt.Sprintf("%s failed: %s", message, err)
+	}
+	claims.Log(level, message)
+}
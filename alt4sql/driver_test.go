@@ -0,0 +1,313 @@
+package alt4sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/alt4dev/go/alt4test"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// statement matches the entry logged for an operation on statement.
+func statement(operation, statement string) func(msg *proto.Log) bool {
+	return func(msg *proto.Log) bool {
+		return alt4test.ClaimValue(msg, "operation") == operation && alt4test.ClaimValue(msg, "statement") == statement
+	}
+}
+
+// fakeDriver is an in-memory driver. Statements containing FAIL return an error and those containing SLOW sleep.
+type fakeDriver struct{}
+
+func (d fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{}, nil
+}
+
+type fakeConn struct{}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func run(query string) error {
+	if strings.Contains(query, "SLOW") {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if strings.Contains(query, "FAIL") {
+		return errors.New("syntax error")
+	}
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := run(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(3), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := run(query); err != nil {
+		return nil, err
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeStmt struct {
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := run(s.query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &fakeRows{}, run(s.query)
+}
+
+type fakeTx struct{}
+
+func (t fakeTx) Commit() error {
+	return nil
+}
+
+func (t fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeRows struct {
+	done bool
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"id"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
+
+var registerOnce sync.Once
+
+func openDB(t *testing.T, opts Options) *sql.DB {
+	registerOnce.Do(func() {
+		sql.Register("alt4-fake", fakeDriver{})
+	})
+	db, err := Open("alt4-fake", "", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestExecAndQuery(t *testing.T) {
+	helper := alt4test.Record()
+	db := openDB(t, Options{})
+	defer db.Close()
+
+	if _, err := db.Exec("UPDATE users SET name = ? WHERE id = ?", []byte("tester"), 10); err != nil {
+		t.Fatal(err)
+	}
+	var id int64
+	if err := db.QueryRow("SELECT id FROM users").Scan(&id); err != nil || id != 1 {
+		t.Fatal("Unexpected query result", id, err)
+	}
+	service.WaitGroup().Wait()
+
+	exec := helper.Find(statement("exec", "UPDATE users SET name = ? WHERE id = ?"))
+	if exec == nil {
+		t.Fatal("Expected exec to be logged")
+	}
+	if exec.Level != proto.Log_DEBUG || alt4test.ClaimValue(exec, "statement") != "UPDATE users SET name = ? WHERE id = ?" {
+		t.Errorf("Unexpected exec entry %v", exec)
+	}
+	expected := map[string]string{"args": "2", "arg.1": "tester", "arg.2": "10", "rows_affected": "3"}
+	for name, value := range expected {
+		if found := alt4test.ClaimValue(exec, name); found != value {
+			t.Errorf("Unexpected value for claim `%s`. '%s' != '%s'", name, found, value)
+		}
+	}
+	if query := helper.Find(statement("query", "SELECT id FROM users")); query == nil {
+		t.Errorf("Expected query to be logged. Found %v", query)
+	}
+}
+
+func TestRedactAndSlow(t *testing.T) {
+	helper := alt4test.Record()
+	db := openDB(t, Options{RedactArgs: true, SlowThreshold: 10 * time.Millisecond})
+	defer db.Close()
+
+	if _, err := db.Exec("SLOW UPDATE users SET password = ?", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	service.WaitGroup().Wait()
+
+	exec := helper.Find(statement("exec", "SLOW UPDATE users SET password = ?"))
+	if exec == nil {
+		t.Fatal("Expected exec to be logged")
+	}
+	if exec.Level != proto.Log_WARNING || alt4test.ClaimValue(exec, "slow") != "true" {
+		t.Errorf("Expected slow statements to be escalated to WARNING. %v", exec)
+	}
+	if alt4test.ClaimValue(exec, "args") != "1" || alt4test.ClaimValue(exec, "arg.1") != "" {
+		t.Errorf("Expected argument values to be redacted. %v", exec.Claims)
+	}
+}
+
+func TestErrorsAndTransactions(t *testing.T) {
+	helper := alt4test.Record()
+	db := openDB(t, Options{})
+	defer db.Close()
+
+	if _, err := db.Exec("FAIL"); err == nil {
+		t.Fatal("Expected the driver's error to be returned")
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO users VALUES (?)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stmt.Exec("tester"); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	service.WaitGroup().Wait()
+
+	if prepared := helper.Find(statement("exec", "INSERT INTO users VALUES (?)")); prepared == nil || alt4test.ClaimValue(prepared, "rows_affected") != "1" {
+		t.Errorf("Expected the prepared statement to be logged. Found %v", prepared)
+	}
+	if failed := helper.Find(statement("exec", "FAIL")); failed == nil || failed.Level != proto.Log_ERROR || alt4test.ClaimValue(failed, "error") != "syntax error" {
+		t.Errorf("Expected the failure to be logged as an error. Found %v", failed)
+	}
+	for _, operation := range []string{"begin", "commit"} {
+		if helper.Find(statement(operation, "")) == nil {
+			t.Errorf("Expected %s to be logged", operation)
+		}
+	}
+
+	// The driver doesn't support options for transactions
+	if _, err = db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable}); err != errIsolationLevel {
+		t.Errorf("Expected an error for the isolation level. Found %v", err)
+	}
+	if _, err = db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true}); err != errReadOnly {
+		t.Errorf("Expected an error for a read-only transaction. Found %v", err)
+	}
+}
+
+type point struct {
+	x, y int
+}
+
+// checkingDriver accepts point arguments with a NamedValueChecker on the connection only.
+type checkingDriver struct{}
+
+func (d checkingDriver) Open(name string) (driver.Conn, error) {
+	return &checkingConn{}, nil
+}
+
+type checkingConn struct {
+	fakeConn
+}
+
+func (c *checkingConn) CheckNamedValue(value *driver.NamedValue) error {
+	if p, ok := value.Value.(point); ok {
+		value.Value = fmt.Sprintf("(%d,%d)", p.x, p.y)
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+// convertingDriver accepts point arguments with a ColumnConverter on statements.
+type convertingDriver struct{}
+
+func (d convertingDriver) Open(name string) (driver.Conn, error) {
+	return &convertingConn{}, nil
+}
+
+type convertingConn struct {
+	fakeConn
+}
+
+func (c *convertingConn) Prepare(query string) (driver.Stmt, error) {
+	return &convertingStmt{fakeStmt{query: query}}, nil
+}
+
+type convertingStmt struct {
+	fakeStmt
+}
+
+func (s *convertingStmt) ColumnConverter(idx int) driver.ValueConverter {
+	return pointConverter{}
+}
+
+type pointConverter struct{}
+
+func (c pointConverter) ConvertValue(v interface{}) (driver.Value, error) {
+	if p, ok := v.(point); ok {
+		return fmt.Sprintf("(%d,%d)", p.x, p.y), nil
+	}
+	return driver.DefaultParameterConverter.ConvertValue(v)
+}
+
+func TestArgumentChecks(t *testing.T) {
+	alt4test.Record()
+	for name, d := range map[string]driver.Driver{"checker": checkingDriver{}, "converter": convertingDriver{}} {
+		db := sql.OpenDB(&dsnConnector{driver: Wrap(d, Options{})})
+		// Column converters only apply to prepared statements
+		if _, err := db.Exec("INSERT INTO points VALUES (?)", point{1, 2}); name == "checker" && err != nil {
+			t.Errorf("Expected the connection's checker to be used. Found %v", err)
+		}
+		prepared, err := db.Prepare("INSERT INTO points VALUES (?)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = prepared.Exec(point{1, 2}); err != nil {
+			t.Errorf("Expected the %s of the wrapped driver to be used for prepared statements. Found %v", name, err)
+		}
+		if _, err = prepared.Exec(struct{}{}); err == nil {
+			t.Errorf("Expected arguments the %s doesn't accept to be rejected", name)
+		}
+		prepared.Close()
+		db.Close()
+	}
+}
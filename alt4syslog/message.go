@@ -0,0 +1,270 @@
+// Package alt4syslog receives syslog messages over UDP, TCP and unix sockets and writes them to alt4.
+// RFC 5424 and RFC 3164 (BSD) messages are supported.
+package alt4syslog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/protobuff/proto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrEmptyMessage is returned when parsing an empty message.
+var ErrEmptyMessage = errors.New("alt4syslog: empty message")
+
+// DefaultPriority the priority of messages without one, user.notice as specified by RFC 3164.
+const DefaultPriority = 13
+
+// Severities as defined by RFC 5424
+const (
+	SeverityEmergency = iota
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInformational
+	SeverityDebug
+)
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// Param is a parameter of a structured data element.
+type Param struct {
+	Name  string
+	Value string
+}
+
+// Element is a structured data element of an RFC 5424 message.
+type Element struct {
+	Id     string
+	Params []Param
+}
+
+// Message is a parsed syslog message. Fields missing from the message are empty.
+type Message struct {
+	Facility  int
+	Severity  int
+	Timestamp time.Time
+	Hostname  string
+	AppName   string
+	ProcId    string
+	MsgId     string
+	// StructuredData the structured data elements of RFC 5424 messages.
+	StructuredData []Element
+	Message        string
+}
+
+// FacilityName returns the name of the facility e.g. `local0`.
+func (msg *Message) FacilityName() string {
+	if msg.Facility >= 0 && msg.Facility < len(facilities) {
+		return facilities[msg.Facility]
+	}
+	return strconv.Itoa(msg.Facility)
+}
+
+// SeverityName returns the name of the severity e.g. `err`.
+func (msg *Message) SeverityName() string {
+	if msg.Severity >= 0 && msg.Severity < len(severities) {
+		return severities[msg.Severity]
+	}
+	return strconv.Itoa(msg.Severity)
+}
+
+// Claims returns the facility, severity, header fields and structured data of the message as claims.
+// Structured data parameters are named `<element id>.<param name>`.
+func (msg *Message) Claims() log.Claims {
+	claims := log.Claims{
+		"facility": msg.FacilityName(),
+		"severity": msg.SeverityName(),
+	}
+	for name, value := range map[string]string{"hostname": msg.Hostname, "app_name": msg.AppName, "proc_id": msg.ProcId, "msg_id": msg.MsgId} {
+		if value != "" {
+			claims[name] = value
+		}
+	}
+	for _, element := range msg.StructuredData {
+		for _, param := range element.Params {
+			claims[element.Id+"."+param.Name] = param.Value
+		}
+	}
+	return claims
+}
+
+// Level maps a syslog severity to an alt4 log level.
+func Level(severity int) proto.Log_Level {
+	switch {
+	case severity <= SeverityCritical:
+		return proto.Log_FATAL
+	case severity == SeverityError:
+		return proto.Log_ERROR
+	case severity == SeverityWarning:
+		return proto.Log_WARNING
+	case severity == SeverityDebug:
+		return proto.Log_DEBUG
+	}
+	return proto.Log_INFO
+}
+
+// Parse parses an RFC 5424 or RFC 3164 message. The format is detected from the version after the priority.
+// Messages that don't follow either format are kept whole as the message with the default priority.
+func Parse(data []byte) (*Message, error) {
+	data = bytes.TrimRight(data, "\r\n\x00")
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, ErrEmptyMessage
+	}
+	msg := &Message{}
+	rest, ok := parsePriority(msg, string(data))
+	if ok && strings.HasPrefix(rest, "1 ") {
+		if err := parse5424(msg, rest[2:]); err == nil {
+			return msg, nil
+		}
+		// Fall back to RFC 3164 which accepts any content
+		msg = &Message{}
+		rest, _ = parsePriority(msg, string(data))
+	}
+	parse3164(msg, rest, time.Now())
+	return msg, nil
+}
+
+// parsePriority reads the `<PRI>` at the start of a message.
+func parsePriority(msg *Message, data string) (rest string, ok bool) {
+	msg.Facility, msg.Severity = DefaultPriority/8, DefaultPriority%8
+	end := strings.IndexByte(data, '>')
+	if !strings.HasPrefix(data, "<") || end < 2 || end > 4 {
+		return data, false
+	}
+	priority, err := strconv.Atoi(data[1:end])
+	if err != nil || priority < 0 || priority > 191 {
+		return data, false
+	}
+	msg.Facility, msg.Severity = priority/8, priority%8
+	return data[end+1:], true
+}
+
+// parse5424 parses the header, structured data and message that follow the version of an RFC 5424 message.
+func parse5424(msg *Message, data string) error {
+	fields := make([]string, 5)
+	for i := range fields {
+		end := strings.IndexByte(data, ' ')
+		if end < 0 {
+			return fmt.Errorf("alt4syslog: incomplete header")
+		}
+		fields[i], data = data[:end], data[end+1:]
+		if fields[i] == "-" {
+			fields[i] = ""
+		}
+	}
+	if fields[0] != "" {
+		timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return err
+		}
+		msg.Timestamp = timestamp
+	}
+	msg.Hostname, msg.AppName, msg.ProcId, msg.MsgId = fields[1], fields[2], fields[3], fields[4]
+
+	if strings.HasPrefix(data, "-") {
+		data = data[1:]
+	} else {
+		var err error
+		if msg.StructuredData, data, err = parseStructuredData(data); err != nil {
+			return err
+		}
+	}
+	data = strings.TrimPrefix(data, " ")
+	msg.Message = strings.TrimPrefix(data, "\ufeff")
+	return nil
+}
+
+// parseStructuredData parses elements like `[id name="value" ...]` until the first character that doesn't start an element.
+func parseStructuredData(data string) (elements []Element, rest string, err error) {
+	for strings.HasPrefix(data, "[") {
+		data = data[1:]
+		end := strings.IndexAny(data, " ]")
+		if end <= 0 {
+			return nil, data, fmt.Errorf("alt4syslog: invalid structured data")
+		}
+		element := Element{Id: data[:end]}
+		data = data[end:]
+		for strings.HasPrefix(data, " ") {
+			data = data[1:]
+			assign := strings.Index(data, "=\"")
+			if assign <= 0 {
+				return nil, data, fmt.Errorf("alt4syslog: invalid structured data parameter")
+			}
+			param := Param{Name: data[:assign]}
+			data = data[assign+2:]
+			var value strings.Builder
+			closed := false
+			for i := 0; i < len(data); i++ {
+				c := data[i]
+				if c == '\\' && i+1 < len(data) && (data[i+1] == '"' || data[i+1] == '\\' || data[i+1] == ']') {
+					value.WriteByte(data[i+1])
+					i++
+					continue
+				}
+				if c == '"' {
+					data = data[i+1:]
+					closed = true
+					break
+				}
+				value.WriteByte(c)
+			}
+			if !closed {
+				return nil, data, fmt.Errorf("alt4syslog: unterminated structured data parameter")
+			}
+			param.Value = value.String()
+			element.Params = append(element.Params, param)
+		}
+		if !strings.HasPrefix(data, "]") {
+			return nil, data, fmt.Errorf("alt4syslog: unterminated structured data element")
+		}
+		data = data[1:]
+		elements = append(elements, element)
+	}
+	return elements, data, nil
+}
+
+// parse3164 parses the BSD format `Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG`. Every part is optional.
+func parse3164(msg *Message, data string, now time.Time) {
+	if len(data) >= 16 && data[15] == ' ' {
+		if timestamp, err := time.ParseInLocation(time.Stamp, data[:15], now.Location()); err == nil {
+			// The year isn't part of the timestamp
+			timestamp = timestamp.AddDate(now.Year(), 0, 0)
+			if timestamp.After(now.AddDate(0, 1, 0)) {
+				timestamp = timestamp.AddDate(-1, 0, 0)
+			}
+			msg.Timestamp = timestamp
+			data = data[16:]
+			// The hostname follows the timestamp unless the next word is the tag
+			if end := strings.IndexByte(data, ' '); end > 0 && !strings.ContainsAny(data[:end], ":[") {
+				msg.Hostname, data = data[:end], data[end+1:]
+			}
+		}
+	}
+
+	// The tag is the name of the program and an optional pid e.g. `sshd[42]:`
+	if end := strings.IndexAny(data, ":[ "); end > 0 && end <= 48 && data[end] != ' ' {
+		tag, rest := data[:end], data[end:]
+		if rest[0] == '[' {
+			if closing := strings.Index(rest, "]:"); closing > 0 {
+				msg.AppName, msg.ProcId = tag, rest[1:closing]
+				data = rest[closing+2:]
+			}
+		} else {
+			msg.AppName = tag
+			data = rest[1:]
+		}
+	}
+	msg.Message = strings.TrimPrefix(data, " ")
+}
@@ -0,0 +1,92 @@
+package alt4syslog
+
+import (
+	"github.com/alt4dev/protobuff/proto"
+	"testing"
+	"time"
+)
+
+func TestParse_RFC5424(t *testing.T) {
+	msg, err := Parse([]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="App\"lication" eventID="1011"][examplePriority@32473 class="high"] ` + "\ufeff" + `An application event log entry...` + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Facility != 20 || msg.Severity != SeverityNotice || msg.FacilityName() != "local4" || msg.SeverityName() != "notice" {
+		t.Errorf("Unexpected priority %d %d", msg.Facility, msg.Severity)
+	}
+	if !msg.Timestamp.Equal(time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC)) {
+		t.Errorf("Unexpected timestamp %s", msg.Timestamp)
+	}
+	if msg.Hostname != "mymachine.example.com" || msg.AppName != "evntslog" || msg.ProcId != "" || msg.MsgId != "ID47" {
+		t.Errorf("Unexpected header %v", msg)
+	}
+	if msg.Message != "An application event log entry..." {
+		t.Errorf("Unexpected message %q", msg.Message)
+	}
+	claims := msg.Claims()
+	expected := map[string]string{
+		"facility": "local4", "severity": "notice", "hostname": "mymachine.example.com", "app_name": "evntslog", "msg_id": "ID47",
+		"exampleSDID@32473.iut": "3", "exampleSDID@32473.eventSource": `App"lication`, "exampleSDID@32473.eventID": "1011", "examplePriority@32473.class": "high",
+	}
+	if len(claims) != len(expected) {
+		t.Errorf("Unexpected claims %v", claims)
+	}
+	for name, value := range expected {
+		if claims[name] != value {
+			t.Errorf("Expected the claim %s=%s. Found %v", name, value, claims[name])
+		}
+	}
+
+	msg, err = Parse([]byte(`<34>1 - - - - - -`))
+	if err != nil || msg.Severity != SeverityCritical || msg.Hostname != "" || !msg.Timestamp.IsZero() || msg.Message != "" {
+		t.Errorf("Expected nil values to be empty. Found %v %v", msg, err)
+	}
+}
+
+func TestParse_RFC3164(t *testing.T) {
+	now := time.Now()
+	msg, err := Parse([]byte(`<34>Oct 11 22:14:15 mymachine su[230]: 'su root' failed for lonvick on /dev/pts/8`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Facility != 4 || msg.Severity != SeverityCritical || msg.Hostname != "mymachine" || msg.AppName != "su" || msg.ProcId != "230" {
+		t.Errorf("Unexpected header %v", msg)
+	}
+	if msg.Message != "'su root' failed for lonvick on /dev/pts/8" {
+		t.Errorf("Unexpected message %q", msg.Message)
+	}
+	if msg.Timestamp.Month() != time.October || msg.Timestamp.Day() != 11 || msg.Timestamp.Hour() != 22 || msg.Timestamp.After(now.AddDate(0, 1, 0)) {
+		t.Errorf("Unexpected timestamp %s", msg.Timestamp)
+	}
+
+	// Without a hostname
+	msg, _ = Parse([]byte(`<13>Feb  5 17:32:18 kernel: device eth0 entered promiscuous mode`))
+	if msg.Hostname != "" || msg.AppName != "kernel" || msg.Message != "device eth0 entered promiscuous mode" {
+		t.Errorf("Unexpected message %v", msg)
+	}
+
+	// Without a priority or timestamp
+	msg, _ = Parse([]byte("link down\n"))
+	if msg.Facility != 1 || msg.Severity != SeverityNotice || msg.Message != "link down" || !msg.Timestamp.IsZero() {
+		t.Errorf("Unexpected message %v", msg)
+	}
+
+	// Invalid RFC 5424 messages are parsed as RFC 3164
+	msg, _ = Parse([]byte(`<11>1 yesterday host app - - - oops`))
+	if msg.Severity != SeverityError || msg.Message != "1 yesterday host app - - - oops" {
+		t.Errorf("Unexpected message %v", msg)
+	}
+
+	if _, err = Parse([]byte("\n")); err != ErrEmptyMessage {
+		t.Errorf("Expected an error for empty messages. Found %v", err)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	expected := []proto.Log_Level{proto.Log_FATAL, proto.Log_FATAL, proto.Log_FATAL, proto.Log_ERROR, proto.Log_WARNING, proto.Log_INFO, proto.Log_INFO, proto.Log_DEBUG}
+	for severity, level := range expected {
+		if Level(severity) != level {
+			t.Errorf("Expected severity %d to be %s. Found %s", severity, level, Level(severity))
+		}
+	}
+}
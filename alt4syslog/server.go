@@ -0,0 +1,265 @@
+package alt4syslog
+
+import (
+	"bufio"
+	"errors"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultMaxMessageSize the size of the largest message read. Longer messages are split.
+const DefaultMaxMessageSize = 64 * 1024
+
+// ErrServerClosed is returned when listening on a closed server.
+var ErrServerClosed = errors.New("alt4syslog: server closed")
+
+// Options configure a server created by NewServer.
+type Options struct {
+	// Source returns the source of the entry written for a message. Defaults to `<hostname>/<app name>`.
+	// The source set through the service package is used if empty.
+	Source func(msg *Message) string
+	// Claims added to every entry.
+	Claims []*proto.Claim
+	// MaxMessageSize the size of the largest message read. Defaults to DefaultMaxMessageSize.
+	MaxMessageSize int
+}
+
+// Server receives syslog messages and writes them to alt4.
+type Server struct {
+	opts   Options
+	lock   sync.Mutex
+	closed bool
+	// listeners the listeners and connections to close with the server. The value is true for listeners.
+	listeners map[io.Closer]bool
+	wg        sync.WaitGroup
+	// writes the entries not yet written to alt4
+	writes sync.WaitGroup
+}
+
+// NewServer creates a server. Use Listen, Serve or ServePacket to receive messages.
+func NewServer(opts Options) *Server {
+	if opts.Source == nil {
+		opts.Source = DefaultSource
+	}
+	if opts.MaxMessageSize <= 0 {
+		opts.MaxMessageSize = DefaultMaxMessageSize
+	}
+	return &Server{opts: opts, listeners: map[io.Closer]bool{}}
+}
+
+// DefaultSource returns `<hostname>/<app name>` or whichever of them is set.
+func DefaultSource(msg *Message) string {
+	switch {
+	case msg.Hostname != "" && msg.AppName != "":
+		return msg.Hostname + "/" + msg.AppName
+	case msg.Hostname != "":
+		return msg.Hostname
+	}
+	return msg.AppName
+}
+
+// Listen listens on the network address and receives messages in the background.
+// Networks `udp`, `udp4`, `udp6` and `unixgram` receive a message per packet.
+// Networks `tcp`, `tcp4`, `tcp6` and `unix` receive messages framed by octet counting or new lines as described by RFC 6587.
+// A stale unix socket at the address is removed.
+func (server *Server) Listen(network string, address string) (net.Addr, error) {
+	if server.isClosed() {
+		return nil, ErrServerClosed
+	}
+	if network == "unix" || network == "unixgram" {
+		if info, err := os.Stat(address); err == nil && info.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(address)
+		}
+	}
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+		conn, err := net.ListenPacket(network, address)
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			_ = server.ServePacket(conn)
+		}()
+		return conn.LocalAddr(), nil
+	default:
+		listener, err := net.Listen(network, address)
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			_ = server.Serve(listener)
+		}()
+		return listener.Addr(), nil
+	}
+}
+
+// track adds a listener or connection to be closed with the server. false is returned if the server is closed.
+func (server *Server) track(c io.Closer, listener bool) bool {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	if server.closed {
+		_ = c.Close()
+		return false
+	}
+	server.listeners[c] = listener
+	server.wg.Add(1)
+	return true
+}
+
+func (server *Server) untrack(c io.Closer) {
+	server.lock.Lock()
+	delete(server.listeners, c)
+	server.lock.Unlock()
+	server.wg.Done()
+}
+
+func (server *Server) isClosed() bool {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+	return server.closed
+}
+
+// ServePacket receives a message per packet until the server is closed.
+func (server *Server) ServePacket(conn net.PacketConn) error {
+	if !server.track(conn, true) {
+		return ErrServerClosed
+	}
+	defer server.untrack(conn)
+	buffer := make([]byte, server.opts.MaxMessageSize)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if n > 0 {
+			server.receive(buffer[:n], addr)
+		}
+		if err != nil {
+			if server.isClosed() {
+				return ErrServerClosed
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Temporary() {
+				continue
+			}
+			return err
+		}
+	}
+}
+
+// Serve accepts connections until the server is closed.
+func (server *Server) Serve(listener net.Listener) error {
+	if !server.track(listener, true) {
+		return ErrServerClosed
+	}
+	defer server.untrack(listener)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if server.isClosed() {
+				return ErrServerClosed
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		if server.track(conn, false) {
+			go server.serveConn(conn)
+		}
+	}
+}
+
+// serveConn reads messages framed by octet counting e.g. `11 <13>1 - ...` or by new lines.
+func (server *Server) serveConn(conn net.Conn) {
+	defer server.untrack(conn)
+	defer conn.Close()
+	reader := bufio.NewReaderSize(conn, server.opts.MaxMessageSize)
+	for {
+		first, err := reader.Peek(1)
+		if err != nil {
+			return
+		}
+		var message []byte
+		if first[0] >= '1' && first[0] <= '9' {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			size, err := strconv.Atoi(length[:len(length)-1])
+			if err != nil || size <= 0 || size > server.opts.MaxMessageSize {
+				return
+			}
+			message = make([]byte, size)
+			if _, err = io.ReadFull(reader, message); err != nil {
+				return
+			}
+		} else {
+			line, err := reader.ReadSlice('\n')
+			if err != nil && err != bufio.ErrBufferFull && len(line) == 0 {
+				return
+			}
+			message = append([]byte{}, line...)
+		}
+		server.receive(message, conn.RemoteAddr())
+	}
+}
+
+func (server *Server) receive(data []byte, addr net.Addr) {
+	received := service.LogTime()
+	msg, err := Parse(data)
+	if err != nil {
+		return
+	}
+	var remoteAddr string
+	if addr != nil {
+		remoteAddr = addr.String()
+	}
+	result := server.Handle(msg, remoteAddr, received)
+	server.writes.Add(1)
+	go func() {
+		defer server.writes.Done()
+		<-result.Done()
+	}()
+}
+
+// Handle writes a message to alt4. The time the message was received is used if the message has no timestamp.
+func (server *Server) Handle(msg *Message, remoteAddr string, received time.Time) *service.LogResult {
+	claims := msg.Claims()
+	if remoteAddr != "" {
+		claims["remote_addr"] = remoteAddr
+	}
+	timestamp := msg.Timestamp
+	if timestamp.IsZero() {
+		timestamp = received
+	}
+	return service.LogEntry(&proto.Log{
+		Source:    server.opts.Source(msg),
+		Message:   msg.Message,
+		Claims:    append(append([]*proto.Claim{}, server.opts.Claims...), claims.ProtoClaims()...),
+		Level:     Level(msg.Severity),
+		Timestamp: uint64(timestamp.UnixNano()),
+	})
+}
+
+// Close stops the listeners, closes open connections and waits for them to finish and for the received messages to be written.
+// The first error closing a listener is returned.
+func (server *Server) Close() error {
+	server.lock.Lock()
+	server.closed = true
+	var err error
+	for c, listener := range server.listeners {
+		if _err := c.Close(); listener && err == nil {
+			err = _err
+		}
+	}
+	server.lock.Unlock()
+	server.wg.Wait()
+	server.writes.Wait()
+	return err
+}
@@ -0,0 +1,115 @@
+package alt4syslog
+
+import (
+	"fmt"
+	"github.com/alt4dev/go/alt4test"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func setUp() *alt4test.Recorder {
+	service.SetMode(service.ModeRelease)
+	return alt4test.Record()
+}
+
+func TestServer(t *testing.T) {
+	helper := setUp()
+	server := NewServer(Options{Claims: []*proto.Claim{{Name: "receiver", Value: "test"}}})
+	defer server.Close()
+
+	udpAddr, err := server.Listen("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("udp", udpAddr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = conn.Write([]byte(`<11>1 2021-03-01T10:00:00Z router1 bgpd 77 - [peer@1 ip="10.0.0.1"] session down`))
+	_ = conn.Close()
+
+	msg := helper.WaitFor(alt4test.Message("session down"), 2*time.Second)
+	if msg == nil {
+		t.Fatal("Expected the udp message to be written")
+	}
+	if msg.Source != "router1/bgpd" || msg.Level != proto.Log_ERROR || msg.Timestamp != uint64(time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC).UnixNano()) {
+		t.Errorf("Unexpected entry %v", msg)
+	}
+	for name, value := range map[string]string{"receiver": "test", "peer@1.ip": "10.0.0.1", "proc_id": "77", "severity": "err"} {
+		if claim := alt4test.Claim(msg, name); claim == nil || claim.Value != value {
+			t.Errorf("Expected the claim %s=%s. Found %v", name, value, claim)
+		}
+	}
+	if alt4test.Claim(msg, "remote_addr") == nil {
+		t.Error("Expected the address of the sender")
+	}
+
+	// Octet counting and new line framing over tcp
+	tcpAddr, err := server.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err = net.Dial("tcp", tcpAddr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	counted := "<12>1 - switch2 - - - - port 3\nflapping"
+	_, _ = fmt.Fprintf(conn, "%d %s", len(counted), counted)
+	_, _ = fmt.Fprint(conn, "<15>Mar  1 10:00:00 switch2 lldpd: neighbor added\n")
+	_ = conn.Close()
+	if msg = helper.WaitFor(alt4test.Message("port 3\nflapping"), 2*time.Second); msg == nil || msg.Level != proto.Log_WARNING || msg.Source != "switch2" {
+		t.Errorf("Expected the octet counted message. Found %v", msg)
+	}
+	if msg = helper.WaitFor(alt4test.Message("neighbor added"), 2*time.Second); msg == nil || msg.Level != proto.Log_DEBUG || msg.Source != "switch2/lldpd" {
+		t.Errorf("Expected the new line framed message. Found %v", msg)
+	}
+}
+
+func TestServer_Unix(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets aren't supported")
+	}
+	helper := setUp()
+	dir, err := ioutil.TempDir("", "alt4syslog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	server := NewServer(Options{Source: func(msg *Message) string {
+		return "local"
+	}})
+
+	path := filepath.Join(dir, "log.sock")
+	if _, err = server.Listen("unixgram", path); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("unixgram", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = conn.Write([]byte("<86>Mar  1 10:00:00 sshd[12]: accepted publickey"))
+	_ = conn.Close()
+	msg := helper.WaitFor(alt4test.Message("accepted publickey"), 2*time.Second)
+	if msg == nil || msg.Source != "local" || alt4test.Claim(msg, "facility").Value != "authpriv" {
+		t.Errorf("Unexpected entry %v", msg)
+	}
+
+	if err = server.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, err = server.Listen("unixgram", path); err != ErrServerClosed {
+		t.Errorf("Expected the server to be closed. Found %v", err)
+	}
+	server = NewServer(Options{})
+	defer server.Close()
+	if _, err = server.Listen("unixgram", path); err != nil {
+		t.Errorf("Expected the stale socket to be replaced. Found %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+// Package alt4test contains helpers for tests of code that uses alt4.
+package alt4test
+
+import (
+	"github.com/alt4dev/go/log"
+	"sync"
+	"testing"
+)
+
+// FailOnViolations fails the test when a claim that doesn't match its schema is logged until the test finishes.
+// See log.RegisterClaim
+func FailOnViolations(t testing.TB) {
+	t.Helper()
+	var lock sync.Mutex
+	finished := false
+	previous := log.SetViolationHandler(func(violation log.Violation) {
+		lock.Lock()
+		defer lock.Unlock()
+		if !finished {
+			t.Errorf("alt4: %s", violation.Error())
+		}
+	})
+	t.Cleanup(func() {
+		lock.Lock()
+		finished = true
+		lock.Unlock()
+		log.SetViolationHandler(previous)
+	})
+}
@@ -0,0 +1,56 @@
+package alt4test
+
+import (
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/protobuff/proto"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, format)
+}
+
+func (r *recorder) Cleanup(f func()) {
+	r.TB.Cleanup(f)
+}
+
+func TestFailOnViolations(t *testing.T) {
+	log.RegisterClaim("test.count", log.ClaimSchema{Type: proto.Claim_NUMBER})
+	defer log.UnregisterClaim("test.count")
+
+	r := &recorder{TB: t}
+	FailOnViolations(r)
+	log.Fields{log.String("test.count", "12")}.ProtoClaims()
+	if len(r.errors) != 0 {
+		t.Fatalf("expected no errors for a value that can be converted, got %v", r.errors)
+	}
+	log.Fields{log.String("test.count", "many")}.ProtoClaims()
+	if len(r.errors) != 1 {
+		t.Fatalf("expected 1 error, got %v", r.errors)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := Record()
+	recorder.Fail("dropped")
+	log.Claims{"user": "tester"}.Info("kept").Result()
+	log.Info("dropped").Result()
+	msg := recorder.WaitFor(Message("kept"), time.Second)
+	if msg == nil || ClaimValue(msg, "user") != "tester" || Claim(msg, "missing") != nil {
+		t.Fatalf("Expected the entry to be recorded. Found %v", recorder.Entries())
+	}
+	if recorder.Find(Message("dropped")) != nil {
+		t.Error("Expected entries that fail to not be recorded")
+	}
+	if len(recorder.Reset()) != 1 || len(recorder.Entries()) != 0 {
+		t.Error("Expected Reset to clear the entries")
+	}
+}
@@ -0,0 +1,108 @@
+package alt4test
+
+import (
+	"errors"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"sync"
+	"time"
+)
+
+// Recorder a service.RemoteHelper that keeps the entries written instead of sending them to alt4.
+type Recorder struct {
+	service.DefaultHelper
+	// Delay how long writes take e.g. to check that entries are waited for. Set it before logging.
+	Delay time.Duration
+	lock  sync.Mutex
+	logs  []*proto.Log
+	fail  string
+}
+
+// Record sets a new Recorder as service.Alt4RemoteHelper and returns it.
+func Record() *Recorder {
+	recorder := &Recorder{}
+	service.Alt4RemoteHelper = recorder
+	return recorder
+}
+
+func (recorder *Recorder) WriteLog(msg *proto.Log, result *service.LogResult) {
+	time.Sleep(recorder.Delay)
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	if recorder.fail != "" && msg.Message == recorder.fail {
+		result.Err = errors.New("unavailable")
+		return
+	}
+	recorder.logs = append(recorder.logs, msg)
+}
+
+// Fail makes writes of entries with the message fail. An empty message stops failing writes.
+func (recorder *Recorder) Fail(message string) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.fail = message
+}
+
+// Entries returns the entries written so far. Writes are asynchronous so entries can arrive in any order.
+func (recorder *Recorder) Entries() []*proto.Log {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	return append([]*proto.Log{}, recorder.logs...)
+}
+
+// Reset clears the entries written so far and returns them.
+func (recorder *Recorder) Reset() []*proto.Log {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	logs := recorder.logs
+	recorder.logs = nil
+	return logs
+}
+
+// Find returns the first entry written that matches or nil.
+func (recorder *Recorder) Find(match func(msg *proto.Log) bool) *proto.Log {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	for _, msg := range recorder.logs {
+		if match(msg) {
+			return msg
+		}
+	}
+	return nil
+}
+
+// WaitFor waits up to timeout for an entry that matches to be written and returns it or nil.
+func (recorder *Recorder) WaitFor(match func(msg *proto.Log) bool, timeout time.Duration) *proto.Log {
+	deadline := time.Now().Add(timeout)
+	for {
+		if msg := recorder.Find(match); msg != nil || time.Now().After(deadline) {
+			return msg
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Message matches entries with the message. Use it with Find and WaitFor.
+func Message(message string) func(msg *proto.Log) bool {
+	return func(msg *proto.Log) bool {
+		return msg.Message == message
+	}
+}
+
+// Claim returns the claim of an entry with the name or nil.
+func Claim(msg *proto.Log, name string) *proto.Claim {
+	for _, claim := range msg.Claims {
+		if claim.Name == name {
+			return claim
+		}
+	}
+	return nil
+}
+
+// ClaimValue returns the value of the claim of an entry with the name or an empty string.
+func ClaimValue(msg *proto.Log, name string) string {
+	if claim := Claim(msg, name); claim != nil {
+		return claim.Value
+	}
+	return ""
+}
@@ -0,0 +1,198 @@
+// Package alt4zap provides a zapcore.Core that writes entries to alt4.
+package alt4zap
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"go.uber.org/zap/zapcore"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Core is a zapcore.Core that writes entries to alt4.
+// Entries are written within the group of the goroutine that logs them.
+type Core struct {
+	zapcore.LevelEnabler
+	claims  []*proto.Claim
+	pending *sync.WaitGroup
+}
+
+// NewCore creates a core that writes entries enabled by enabler.
+// Example: logger := zap.New(alt4zap.NewCore(zapcore.DebugLevel), zap.AddCaller())
+// Caller information is only available when the logger is created with zap.AddCaller().
+func NewCore(enabler zapcore.LevelEnabler) *Core {
+	return &Core{LevelEnabler: enabler, pending: &sync.WaitGroup{}}
+}
+
+// Level maps a zap level to an alt4 log level. DPanic, Panic and Fatal map to FATAL.
+func Level(level zapcore.Level) proto.Log_Level {
+	switch {
+	case level < zapcore.InfoLevel:
+		return proto.Log_DEBUG
+	case level == zapcore.InfoLevel:
+		return proto.Log_INFO
+	case level == zapcore.WarnLevel:
+		return proto.Log_WARNING
+	case level == zapcore.ErrorLevel:
+		return proto.Log_ERROR
+	default:
+		return proto.Log_FATAL
+	}
+}
+
+// With returns a core whose entries include fields. Claims for fields are computed once.
+// The returned core shares pending writes with its parent so Sync on either waits for both.
+func (core *Core) With(fields []zapcore.Field) zapcore.Core {
+	claims := make([]*proto.Claim, len(core.claims))
+	copy(claims, core.claims)
+	return &Core{
+		LevelEnabler: core.LevelEnabler,
+		claims:       append(claims, Claims(fields)...),
+		pending:      core.pending,
+	}
+}
+
+// Check adds the core to the checked entry if the level is enabled.
+func (core *Core) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if core.Enabled(entry.Level) {
+		return checked.AddCore(entry, core)
+	}
+	return checked
+}
+
+// Write sends an entry to alt4. Entries above the error level e.g. Fatal are written synchronously as zap may exit after.
+func (core *Core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	claims := make([]*proto.Claim, len(core.claims), len(core.claims)+len(fields)+2)
+	copy(claims, core.claims)
+	claims = append(claims, Claims(fields)...)
+	if entry.LoggerName != "" {
+		claims = append(claims, &proto.Claim{Name: "logger", Type: proto.Claim_STRING, Value: entry.LoggerName})
+	}
+	if entry.Stack != "" {
+		claims = append(claims, &proto.Claim{Name: "stacktrace", Type: proto.Claim_STRING, Value: entry.Stack})
+	}
+
+	var file, function string
+	var line int
+	if entry.Caller.Defined {
+		file, line = entry.Caller.File, entry.Caller.Line
+		if fn := runtime.FuncForPC(entry.Caller.PC); fn != nil {
+			function = fn.Name()
+		}
+	}
+	logTime := entry.Time
+	if logTime.IsZero() {
+		logTime = service.LogTime()
+	}
+	result := service.LogCaller(file, line, function, false, entry.Message, claims, Level(entry.Level), logTime)
+
+	if entry.Level > zapcore.ErrorLevel {
+		_, err := result.Result()
+		return err
+	}
+	core.pending.Add(1)
+	go func() {
+		defer core.pending.Done()
+		<-result.Done()
+	}()
+	return nil
+}
+
+// Sync waits for the pending writes of the core.
+func (core *Core) Sync() error {
+	core.pending.Wait()
+	return nil
+}
+
+// Claims converts zap fields to claims. Nested objects and arrays are flattened into dotted claim names e.g. `user.id`, `items.0`
+func Claims(fields []zapcore.Field) []*proto.Claim {
+	if len(fields) == 0 {
+		return nil
+	}
+	encoder := zapcore.NewMapObjectEncoder()
+	for _, field := range fields {
+		field.AddTo(encoder)
+	}
+	return appendMap(nil, "", encoder.Fields)
+}
+
+func appendMap(claims []*proto.Claim, prefix string, fields map[string]interface{}) []*proto.Claim {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		claims = appendValue(claims, prefix+key, fields[key])
+	}
+	return claims
+}
+
+func appendValue(claims []*proto.Claim, name string, value interface{}) []*proto.Claim {
+	var claimType proto.Claim_Type
+	var claimValue string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return appendMap(claims, name+".", v)
+	case []interface{}:
+		for i, item := range v {
+			claims = appendValue(claims, name+"."+strconv.Itoa(i), item)
+		}
+		return claims
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
+		claimType = proto.Claim_NUMBER
+		claimValue = fmt.Sprint(v)
+	case float32:
+		claimType, claimValue = floatClaim(float64(v), 32)
+	case float64:
+		claimType, claimValue = floatClaim(v, 64)
+	case bool:
+		claimType = proto.Claim_BOOLEAN
+		claimValue = strconv.FormatBool(v)
+	case string:
+		claimType = proto.Claim_STRING
+		claimValue = v
+	case []byte:
+		claimType = proto.Claim_STRING
+		claimValue = string(v)
+	case time.Time:
+		claimType = proto.Claim_TIMESTAMP
+		claimValue = strconv.FormatInt(v.UnixNano(), 10)
+	case time.Duration:
+		// Like zap's default encoders and log.Duration durations are recorded in nanoseconds
+		return append(claims,
+			&proto.Claim{Name: name, Type: proto.Claim_NUMBER, Value: strconv.FormatInt(int64(v), 10)},
+			&proto.Claim{Name: name + ".unit", Type: proto.Claim_STRING, Value: log.DurationUnit},
+		)
+	case error:
+		claimType = proto.Claim_STRING
+		claimValue = v.Error()
+	case fmt.Stringer:
+		claimType = proto.Claim_STRING
+		claimValue = v.String()
+	default:
+		// Values added with zap.Reflect or zap.Any
+		claimType = proto.Claim_STRING
+		if encoded, err := json.Marshal(v); err == nil {
+			claimValue = string(encoded)
+		} else {
+			claimValue = fmt.Sprint(v)
+		}
+	}
+	return append(claims, &proto.Claim{Name: name, Type: claimType, Value: claimValue})
+}
+
+// floatClaim writes NaN and infinities as strings like log.Float64 since they aren't numbers alt4 can query.
+func floatClaim(value float64, bitSize int) (proto.Claim_Type, string) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return proto.Claim_STRING, strconv.FormatFloat(value, 'g', -1, bitSize)
+	}
+	return proto.Claim_NUMBER, strconv.FormatFloat(value, 'g', -1, bitSize)
+}
@@ -0,0 +1,103 @@
+package alt4zap
+
+import (
+	"errors"
+	"github.com/alt4dev/go/alt4test"
+	"github.com/alt4dev/protobuff/proto"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"math"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func whereAmI() int {
+	_, _, l, _ := runtime.Caller(1)
+	return l
+}
+
+type user struct {
+	id int
+}
+
+func (u user) MarshalLogObject(encoder zapcore.ObjectEncoder) error {
+	encoder.AddInt("id", u.id)
+	encoder.AddBool("admin", true)
+	return nil
+}
+
+func TestCore(t *testing.T) {
+	helper := alt4test.Record()
+	helper.Delay = 20 * time.Millisecond
+	logger := zap.New(NewCore(zapcore.InfoLevel), zap.AddCaller()).Named("api").With(zap.String("service", "orders"))
+
+	logger.Debug("not enabled")
+	line := whereAmI() + 1
+	logger.Warn("slow request",
+		zap.Duration("took", 2*time.Second),
+		zap.Object("user", user{id: 7}),
+		zap.Strings("tags", []string{"a", "b"}),
+		zap.Error(errors.New("timeout")),
+		zap.Time("at", time.Unix(10, 0)),
+		zap.Float64("ratio", 0.5),
+		zap.Float64("score", math.NaN()),
+		zap.Float32("limit", float32(math.Inf(-1))),
+	)
+	// Sync waits for the pending write
+	_ = logger.Sync()
+
+	logs := helper.Entries()
+	if len(logs) != 1 {
+		t.Fatalf("Expected a single entry. Found %v", logs)
+	}
+	msg := logs[0]
+	if msg.Message != "slow request" || msg.Level != proto.Log_WARNING {
+		t.Errorf("Unexpected entry %v", msg)
+	}
+	_, file, _, _ := runtime.Caller(0)
+	if msg.File != file || msg.Line != uint32(line) || msg.Function != "github.com/alt4dev/go/alt4zap.TestCore" {
+		t.Errorf("Expected zap's caller to be used. %s:%d %s", msg.File, msg.Line, msg.Function)
+	}
+	type claim struct {
+		Type  proto.Claim_Type
+		Value string
+	}
+	expected := map[string]claim{
+		"service":    {proto.Claim_STRING, "orders"},
+		"logger":     {proto.Claim_STRING, "api"},
+		"took":       {proto.Claim_NUMBER, "2000000000"},
+		"took.unit":  {proto.Claim_STRING, "ns"},
+		"ratio":      {proto.Claim_NUMBER, "0.5"},
+		"score":      {proto.Claim_STRING, "NaN"},
+		"limit":      {proto.Claim_STRING, "-Inf"},
+		"user.id":    {proto.Claim_NUMBER, "7"},
+		"user.admin": {proto.Claim_BOOLEAN, "true"},
+		"tags.1":     {proto.Claim_STRING, "b"},
+		"error":      {proto.Claim_STRING, "timeout"},
+		"at":         {proto.Claim_TIMESTAMP, "10000000000"},
+	}
+	for name, value := range expected {
+		found := alt4test.Claim(msg, name)
+		if found == nil || found.Type != value.Type || found.Value != value.Value {
+			t.Errorf("Unexpected claim `%s`. %v", name, found)
+		}
+	}
+}
+
+func TestCorePanic(t *testing.T) {
+	helper := alt4test.Record()
+	helper.Delay = 20 * time.Millisecond
+	logger := zap.New(NewCore(zapcore.DebugLevel))
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		logger.Panic("failed")
+	}()
+	// Entries above the error level are written before zap panics or exits
+	logs := helper.Entries()
+	if len(logs) != 1 || logs[0].Level != proto.Log_FATAL {
+		t.Errorf("Expected the entry to be written synchronously. Found %v", logs)
+	}
+}
@@ -0,0 +1,198 @@
+// Command alt4-run runs a command and writes its output to alt4.
+// The output of the command is logged in a group titled after the command and is still written to the terminal.
+// The exit code, signal, duration and resource usage of the command are logged when it exits.
+// alt4-run exits with the exit code of the command.
+//
+// Usage:
+//
+//	alt4-run [flags] -- command [args...]
+//
+// alt4 is configured through the environment variables ALT4_CONFIG, ALT4_AUTH_TOKEN, ALT4_MODE and ALT4_SOURCE.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// ExitNotFound the exit code when the command can't be found.
+	ExitNotFound = 127
+	// ExitNotStarted the exit code when the command can't be started.
+	ExitNotStarted = 126
+	// ExitUsage the exit code when the arguments are invalid.
+	ExitUsage = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// levelFlag is a log level passed by name e.g. `-stderr-level error`.
+type levelFlag struct {
+	level *proto.Log_Level
+}
+
+func (flag levelFlag) String() string {
+	if flag.level == nil {
+		return ""
+	}
+	return flag.level.String()
+}
+
+func (flag levelFlag) Set(value string) error {
+	level, ok := service.ParseLevel(value)
+	if !ok {
+		return fmt.Errorf("unknown log level `%s`", value)
+	}
+	*flag.level = level
+	return nil
+}
+
+// line is a complete entry read from the output of the command.
+type line struct {
+	dst  io.Writer
+	data []byte
+}
+
+// forwarder sends entries to the goroutine that holds the log group.
+// Logs are grouped per goroutine so all entries must be written from the goroutine that opened the group.
+type forwarder struct {
+	dst   io.Writer
+	lines chan<- line
+}
+
+func (writer forwarder) Write(p []byte) (n int, err error) {
+	writer.lines <- line{dst: writer.dst, data: append([]byte{}, p...)}
+	return len(p), nil
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("alt4-run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	title := flags.String("title", "", "Title of the log group. Defaults to the command")
+	source := flags.String("source", "", "Source of the logs. Overrides ALT4_SOURCE")
+	merge := flags.Bool("merge", true, "Merge indented lines and stack traces into a single entry")
+	stdoutLevel, stderrLevel := proto.Log_INFO, proto.Log_WARNING
+	flags.Var(levelFlag{&stdoutLevel}, "stdout-level", "Level of lines written to stdout")
+	flags.Var(levelFlag{&stderrLevel}, "stderr-level", "Level of lines written to stderr")
+	baseClaims := log.Claims{}
+	flags.Var(log.ClaimsFlag(baseClaims), "claim", "Claim added to every entry as name=value. Can be repeated")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(stderr, "Usage: alt4-run [flags] -- command [args...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+	service.SetSource(*source)
+
+	command := flags.Args()
+	if *title == "" {
+		*title = strings.Join(command, " ")
+	}
+	claims := log.Claims{"command": command[0]}
+	if len(command) > 1 {
+		claims["args"] = strings.Join(command[1:], " ")
+	}
+	for name, value := range baseClaims {
+		claims[name] = value
+	}
+	group := claims.Group(*title)
+	defer group.Close()
+
+	lines := make(chan line)
+	stdoutLines := service.NewLineWriter(forwarder{
+		dst:   service.NewWriter(service.WriterOptions{CallDepth: -1, DefaultLevel: stdoutLevel, Claims: streamClaims("stdout", baseClaims)}),
+		lines: lines,
+	}, service.LineWriterOptions{MergeContinuations: *merge})
+	stderrLines := service.NewLineWriter(forwarder{
+		dst:   service.NewWriter(service.WriterOptions{CallDepth: -1, DefaultLevel: stderrLevel, Claims: streamClaims("stderr", baseClaims)}),
+		lines: lines,
+	}, service.LineWriterOptions{MergeContinuations: *merge})
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(stdout, stdoutLines)
+	cmd.Stderr = io.MultiWriter(stderr, stderrLines)
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		code := ExitNotStarted
+		if errors.Is(err, exec.ErrNotFound) || os.IsNotExist(err) {
+			code = ExitNotFound
+		}
+		claims["exit_code"] = code
+		claims["error"] = err.Error()
+		claims.Error("failed to start command: ", err)
+		_, _ = fmt.Fprintln(stderr, "alt4-run:", err)
+		return code
+	}
+	claims["pid"] = cmd.Process.Pid
+
+	// Signals sent to alt4-run are passed on so that the command can exit gracefully
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+
+	var waitErr error
+	go func() {
+		waitErr = cmd.Wait()
+		_ = stdoutLines.Close()
+		_ = stderrLines.Close()
+		close(lines)
+	}()
+	for l := range lines {
+		_, _ = l.dst.Write(l.data)
+	}
+	duration := time.Since(start)
+
+	code, sig := exitStatus(cmd.ProcessState)
+	claims["exit_code"] = code
+	claims["duration_ms"] = float64(duration) / float64(time.Millisecond)
+	for name, value := range usageClaims(cmd.ProcessState) {
+		claims[name] = value
+	}
+
+	switch {
+	case sig != "":
+		claims["signal"] = sig
+		claims.Error("command killed by signal ", sig)
+	case code != 0:
+		claims.Errorf("command exited with code %d", code)
+	case waitErr != nil:
+		claims["error"] = waitErr.Error()
+		claims.Error("command failed: ", waitErr)
+	default:
+		claims.Infof("command exited with code %d", code)
+	}
+	return code
+}
+
+func streamClaims(stream string, claims log.Claims) []*proto.Claim {
+	withStream := log.Claims{"stream": stream}
+	for name, value := range claims {
+		withStream[name] = value
+	}
+	return withStream.ProtoClaims()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"github.com/alt4dev/go/alt4test"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"os/exec"
+	"testing"
+)
+
+func setUp() *alt4test.Recorder {
+	service.SetMode(service.ModeRelease)
+	return alt4test.Record()
+}
+
+func TestRun(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh isn't available")
+	}
+	helper := setUp()
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-claim", "job=backup", "-stderr-level", "error", "--", "sh", "-c", "echo started; echo failed >&2; exit 3"}, &stdout, &stderr)
+	if code != 3 {
+		t.Errorf("Expected the exit code of the command. Found %d", code)
+	}
+	if stdout.String() != "started\n" || stderr.String() != "failed\n" {
+		t.Errorf("Expected the output to be written to the terminal. Found %q %q", stdout.String(), stderr.String())
+	}
+
+	group := helper.Find(alt4test.Message("sh -c echo started; echo failed >&2; exit 3"))
+	if group == nil || !group.Group || alt4test.Claim(group, "command").Value != "sh" || alt4test.Claim(group, "job") == nil {
+		t.Fatalf("Expected a group titled after the command. Found %v", group)
+	}
+	started := helper.Find(alt4test.Message("started"))
+	if started == nil || started.Level != proto.Log_INFO || started.Thread != group.Thread {
+		t.Fatalf("Expected the stdout line in the group. Found %v", started)
+	}
+	if alt4test.Claim(started, "stream").Value != "stdout" || alt4test.Claim(started, "job").Value != "backup" {
+		t.Errorf("Unexpected claims %v", started.Claims)
+	}
+	failed := helper.Find(alt4test.Message("failed"))
+	if failed == nil || failed.Level != proto.Log_ERROR || failed.Thread != group.Thread || alt4test.Claim(failed, "stream").Value != "stderr" {
+		t.Fatalf("Expected the stderr line in the group. Found %v", failed)
+	}
+	exited := helper.Find(alt4test.Message("command exited with code 3"))
+	if exited == nil || exited.Level != proto.Log_ERROR || exited.Thread != group.Thread {
+		t.Fatalf("Expected the exit to be logged. Found %v", exited)
+	}
+	for _, name := range []string{"exit_code", "duration_ms", "pid"} {
+		if claim := alt4test.Claim(exited, name); claim == nil || claim.Type != proto.Claim_NUMBER {
+			t.Errorf("Expected the claim %s. Found %v", name, claim)
+		}
+	}
+	if alt4test.Claim(exited, "exit_code").Value != "3" {
+		t.Errorf("Unexpected exit code %v", alt4test.Claim(exited, "exit_code"))
+	}
+}
+
+func TestRun_Signal(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh isn't available")
+	}
+	helper := setUp()
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-title", "killed", "--", "sh", "-c", "kill -9 $$"}, &stdout, &stderr)
+	if code != 128+9 {
+		t.Errorf("Expected the exit code of a killed process. Found %d", code)
+	}
+	killed := helper.Find(alt4test.Message("command killed by signal killed"))
+	if killed == nil || killed.Level != proto.Log_ERROR || alt4test.Claim(killed, "signal").Value != "killed" {
+		t.Errorf("Expected the signal to be logged. Found %v", killed)
+	}
+}
+
+func TestRun_NotFound(t *testing.T) {
+	helper := setUp()
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"--", "alt4-run-missing-command"}, &stdout, &stderr); code != ExitNotFound {
+		t.Errorf("Expected the exit code for a missing command. Found %d", code)
+	}
+	if helper.Find(alt4test.Message("alt4-run-missing-command")) == nil {
+		t.Error("Expected a group for the command")
+	}
+	if code := run([]string{"-stdout-level", "loud", "--", "true"}, &stdout, &stderr); code != ExitUsage {
+		t.Errorf("Expected invalid flags to fail. Found %d", code)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import (
+	"github.com/alt4dev/go/log"
+	"os"
+	"time"
+)
+
+func exitStatus(state *os.ProcessState) (code int, signal string) {
+	return state.ExitCode(), ""
+}
+
+func usageClaims(state *os.ProcessState) log.Claims {
+	return log.Claims{
+		"user_time_ms":   float64(state.UserTime()) / float64(time.Millisecond),
+		"system_time_ms": float64(state.SystemTime()) / float64(time.Millisecond),
+	}
+}
@@ -0,0 +1,39 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package main
+
+import (
+	"github.com/alt4dev/go/log"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// exitStatus returns the exit code of a process. Processes killed by a signal exit with 128 + the signal number like in shells.
+func exitStatus(state *os.ProcessState) (code int, signal string) {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if ok && status.Signaled() {
+		return 128 + int(status.Signal()), status.Signal().String()
+	}
+	return state.ExitCode(), ""
+}
+
+// usageClaims returns the resources used by a process.
+func usageClaims(state *os.ProcessState) log.Claims {
+	usage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || usage == nil {
+		return nil
+	}
+	maxRss := int64(usage.Maxrss)
+	if runtime.GOOS == "darwin" {
+		// Reported in bytes instead of kilobytes
+		maxRss /= 1024
+	}
+	return log.Claims{
+		"user_time_ms":   float64(usage.Utime.Nano()) / float64(time.Millisecond),
+		"system_time_ms": float64(usage.Stime.Nano()) / float64(time.Millisecond),
+		"max_rss_kb":     maxRss,
+	}
+}
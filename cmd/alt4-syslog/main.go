@@ -0,0 +1,65 @@
+// Command alt4-syslog receives syslog messages and writes them to alt4.
+// RFC 5424 and RFC 3164 messages are received over UDP, TCP and unix sockets.
+// The source of each entry is the hostname and app name of the message unless `-source` is set.
+//
+// Usage:
+//
+//	alt4-syslog [-udp :514] [-tcp :514] [-unix /dev/log] [-unixgram /dev/log]
+//
+// alt4 is configured through the environment variables ALT4_CONFIG, ALT4_AUTH_TOKEN and ALT4_MODE.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/alt4dev/go/alt4syslog"
+	"github.com/alt4dev/go/log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	udp := flag.String("udp", ":514", "UDP address to listen on. Set to an empty string to disable")
+	tcp := flag.String("tcp", "", "TCP address to listen on")
+	unix := flag.String("unix", "", "Path of a unix stream socket to listen on")
+	unixgram := flag.String("unixgram", "", "Path of a unix datagram socket to listen on e.g. /dev/log")
+	source := flag.String("source", "", "Source of all entries instead of the hostname and app name of each message")
+	claims := log.Claims{}
+	flag.Var(log.ClaimsFlag(claims), "claim", "Claim added to every entry as name=value. Can be repeated")
+	flag.Parse()
+
+	opts := alt4syslog.Options{Claims: claims.ProtoClaims()}
+	if *source != "" {
+		opts.Source = func(msg *alt4syslog.Message) string {
+			return *source
+		}
+	}
+	server := alt4syslog.NewServer(opts)
+	listening := false
+	for _, listener := range []struct {
+		network string
+		address string
+	}{{"udp", *udp}, {"tcp", *tcp}, {"unix", *unix}, {"unixgram", *unixgram}} {
+		if listener.address == "" {
+			continue
+		}
+		addr, err := server.Listen(listener.network, listener.address)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "alt4-syslog: error listening on %s %s: %s\n", listener.network, listener.address, err)
+			_ = server.Close()
+			os.Exit(1)
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "alt4-syslog: listening on %s %s\n", listener.network, addr)
+		listening = true
+	}
+	if !listening {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	_ = server.Close()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// fingerprintSize the number of bytes at the start of a file used to identify it.
+const fingerprintSize = 1024
+
+// position is where reading of a file stopped.
+type position struct {
+	Offset int64 `json:"offset"`
+	// Fingerprint identifies the file so that a different file at the same path e.g. after rotation isn't resumed at the offset.
+	Fingerprint string `json:"fingerprint"`
+}
+
+// checkpoints persist the positions of followed files so that restarts neither duplicate nor skip lines.
+type checkpoints struct {
+	path      string
+	positions map[string]position
+	// changed whether positions changed since they were last saved
+	changed bool
+}
+
+// loadCheckpoints reads the positions saved at path. Nothing is persisted if path is empty.
+func loadCheckpoints(path string) (*checkpoints, error) {
+	c := &checkpoints{path: path, positions: map[string]position{}}
+	if path == "" {
+		return c, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(content, &c.positions); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// set sets the position of a file.
+func (c *checkpoints) set(path string, p position) {
+	if saved, ok := c.positions[path]; !ok || saved != p {
+		c.positions[path] = p
+		c.changed = true
+	}
+}
+
+// remove removes the position of a file.
+func (c *checkpoints) remove(path string) {
+	if _, ok := c.positions[path]; ok {
+		delete(c.positions, path)
+		c.changed = true
+	}
+}
+
+// save writes the positions to a temporary file that replaces the checkpoint file so that it's never partially written.
+// Nothing is written if the positions haven't changed since they were last saved.
+func (c *checkpoints) save() error {
+	if c.path == "" || !c.changed {
+		return nil
+	}
+	content, err := json.MarshalIndent(c.positions, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(content); err == nil {
+		err = tmp.Sync()
+	}
+	if _err := tmp.Close(); err == nil {
+		err = _err
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err = os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+	c.changed = false
+	return nil
+}
+
+// fingerprint hashes the first bytes of a file up to offset.
+func fingerprint(file *os.File, offset int64) (string, error) {
+	size := offset
+	if size > fingerprintSize {
+		size = fingerprintSize
+	}
+	buffer := make([]byte, size)
+	if _, err := file.ReadAt(buffer, 0); err != nil && err != io.EOF {
+		return "", err
+	}
+	sum := sha1.Sum(buffer)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"regexp"
+)
+
+// extractor reads the message, level and claims of a line.
+type extractor struct {
+	// patterns are matched in order and the first match is used.
+	// The named groups `level` and `message` set the level and message of the entry, other named groups are added as claims.
+	patterns []*regexp.Regexp
+	// levelRules detect the level of lines that have no `level` group.
+	levelRules   []service.LevelRule
+	defaultLevel proto.Log_Level
+	claims       log.Claims
+}
+
+func (e *extractor) extract(line string) (message string, level proto.Log_Level, claims []*proto.Claim) {
+	message, level = line, proto.Log_NONE
+	lineClaims := log.Claims{}
+	for name, value := range e.claims {
+		lineClaims[name] = value
+	}
+	for _, pattern := range e.patterns {
+		match := pattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		for i, name := range pattern.SubexpNames() {
+			if name == "" || i >= len(match) || match[i] == "" {
+				continue
+			}
+			switch name {
+			case "message":
+				message = match[i]
+			case "level":
+				if l, ok := service.ParseLevel(match[i]); ok {
+					level = l
+				}
+			default:
+				lineClaims[name] = match[i]
+			}
+		}
+		break
+	}
+	if level == proto.Log_NONE {
+		level = e.defaultLevel
+		for _, rule := range e.levelRules {
+			if match := rule.Pattern.FindStringIndex(message); match != nil {
+				level = rule.Level
+				message = message[:match[0]] + message[match[1]:]
+				break
+			}
+		}
+	}
+	return message, level, lineClaims.ProtoClaims()
+}
@@ -0,0 +1,137 @@
+// Command alt4-tail follows log files and writes new lines to alt4.
+// Files renamed or truncated by log rotation are followed to the new file.
+// The position in each file is saved to a checkpoint file after the lines are written so that restarts neither duplicate nor skip lines.
+//
+// Usage:
+//
+//	alt4-tail [flags] file|glob...
+//
+// alt4 is configured through the environment variables ALT4_CONFIG, ALT4_AUTH_TOKEN, ALT4_MODE and ALT4_SOURCE.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ExitUsage the exit code when the arguments are invalid.
+const ExitUsage = 2
+
+var errorOutput io.Writer = os.Stderr
+
+func emitError(format string, v ...interface{}) {
+	_, _ = fmt.Fprintf(errorOutput, "alt4-tail: "+format+"\n", v...)
+}
+
+func fileClaim(path string) *proto.Claim {
+	return &proto.Claim{Name: "file", Type: proto.Claim_STRING, Value: path}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], nil))
+}
+
+// patternFlags collects regular expressions passed as `-pattern regexp`.
+type patternFlags struct {
+	patterns *[]*regexp.Regexp
+}
+
+func (flag patternFlags) String() string {
+	if flag.patterns == nil {
+		return ""
+	}
+	patterns := make([]string, 0, len(*flag.patterns))
+	for _, pattern := range *flag.patterns {
+		patterns = append(patterns, pattern.String())
+	}
+	return strings.Join(patterns, ",")
+}
+
+func (flag patternFlags) Set(value string) error {
+	pattern, err := regexp.Compile(value)
+	if err != nil {
+		return err
+	}
+	*flag.patterns = append(*flag.patterns, pattern)
+	return nil
+}
+
+// run follows the files until stop is closed or an interrupt is received. The files are read once if `-once` is set.
+func run(args []string, stop <-chan struct{}) int {
+	flags := flag.NewFlagSet("alt4-tail", flag.ContinueOnError)
+	flags.SetOutput(errorOutput)
+	checkpointPath := flags.String("checkpoint", "alt4-tail.checkpoint.json", "File where the position in each file is saved. Set to an empty string to disable")
+	interval := flags.Duration("poll", time.Second, "How often files are checked for new lines")
+	fromStart := flags.Bool("from-start", false, "Read files without a saved position from the start instead of the end")
+	once := flags.Bool("once", false, "Read the files once and exit")
+	source := flags.String("source", "", "Source of the logs. Overrides ALT4_SOURCE")
+	levelName := flags.String("level", "info", "Level of lines whose level isn't detected")
+	detect := flags.Bool("detect-level", true, "Detect levels from markers like [ERROR] or WARN: at the start of lines")
+	e := &extractor{claims: log.Claims{}}
+	flags.Var(patternFlags{&e.patterns}, "pattern", "Regular expression matched against each line. "+
+		"The named groups `level` and `message` set the level and message, other named groups are added as claims. Can be repeated")
+	flags.Var(log.ClaimsFlag(e.claims), "claim", "Claim added to every entry as name=value. Can be repeated")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(errorOutput, "Usage: alt4-tail [flags] file|glob...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return ExitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+	level, ok := service.ParseLevel(*levelName)
+	if !ok {
+		emitError("unknown log level `%s`", *levelName)
+		return ExitUsage
+	}
+	e.defaultLevel = level
+	if *detect {
+		e.levelRules = service.DefaultLevelRules
+	}
+	service.SetSource(*source)
+
+	c, err := loadCheckpoints(*checkpointPath)
+	if err != nil {
+		emitError("error reading checkpoint `%s`: %s", *checkpointPath, err)
+		return 1
+	}
+	t := newTailer(flags.Args(), *fromStart, e, c)
+	defer t.close()
+	if err = t.poll(); err != nil {
+		emitError("error saving checkpoint: %s", err)
+	}
+	if *once {
+		return 0
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-signals:
+			return 0
+		case <-stop:
+			return 0
+		}
+		if err = t.poll(); err != nil {
+			emitError("error saving checkpoint: %s", err)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"github.com/alt4dev/go/service"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxLineSize lines longer than this are split into several entries.
+const maxLineSize = 64 * 1024
+
+// follower reads the lines appended to a file.
+type follower struct {
+	path   string
+	file   *os.File
+	info   os.FileInfo
+	offset int64
+}
+
+// tailer follows the files matching a list of paths or globs.
+type tailer struct {
+	patterns    []string
+	fromStart   bool
+	extractor   *extractor
+	checkpoints *checkpoints
+	followers   map[string]*follower
+	// started is false before the first poll. Files found afterwards are always read from the start.
+	started bool
+	// pending entries written since the last checkpoint
+	pending []pendingEntry
+}
+
+// pendingEntry is an entry waiting to be acknowledged by alt4 before the position after it's saved.
+type pendingEntry struct {
+	follower *follower
+	// file the file the entry was read from. The follower's file changes when it's rotated.
+	file   *os.File
+	start  int64
+	result *service.LogResult
+}
+
+func newTailer(patterns []string, fromStart bool, e *extractor, c *checkpoints) *tailer {
+	return &tailer{
+		patterns:    patterns,
+		fromStart:   fromStart,
+		extractor:   e,
+		checkpoints: c,
+		followers:   map[string]*follower{},
+	}
+}
+
+// paths returns the files matching the patterns.
+func (t *tailer) paths() []string {
+	found := map[string]bool{}
+	for _, pattern := range t.patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			emitError("invalid pattern `%s`: %s", pattern, err)
+			continue
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+				found[match] = true
+			}
+		}
+	}
+	paths := make([]string, 0, len(found))
+	for path := range found {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// poll writes the lines appended since the last poll and saves the checkpoint once they're written.
+func (t *tailer) poll() error {
+	for _, path := range t.paths() {
+		if _, ok := t.followers[path]; !ok {
+			t.followers[path] = &follower{path: path}
+		}
+	}
+	for path, f := range t.followers {
+		if !t.follow(f) {
+			delete(t.followers, path)
+		}
+	}
+	t.started = true
+	return t.checkpoint()
+}
+
+// follow reads a file and handles rotation. false is returned if the file no longer exists.
+func (t *tailer) follow(f *follower) bool {
+	if f.file == nil {
+		if err := t.open(f); err != nil {
+			if !os.IsNotExist(err) {
+				emitError("error opening `%s`: %s", f.path, err)
+			}
+			return !os.IsNotExist(err)
+		}
+	}
+	t.read(f, false)
+
+	info, err := os.Stat(f.path)
+	switch {
+	case err != nil || !os.SameFile(info, f.info):
+		// Rotated by renaming or removing the file. Lines written to the old file before it was replaced are read before closing it.
+		t.read(f, true)
+		_ = f.file.Close()
+		f.file = nil
+		f.offset = 0
+		if err != nil {
+			t.checkpoints.remove(f.path)
+			return false
+		}
+		// The new file is opened on the next poll
+		t.checkpoints.set(f.path, position{})
+	case info.Size() < f.offset:
+		// Truncated in place
+		f.offset = 0
+		t.read(f, false)
+	}
+	return true
+}
+
+// open opens a file and finds where reading should resume.
+func (t *tailer) open(f *follower) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	f.file, f.info, f.offset = file, info, 0
+
+	saved, ok := t.checkpoints.positions[f.path]
+	switch {
+	case ok && saved.Offset <= info.Size():
+		// Resume only if it's the same file
+		if sum, err := fingerprint(file, saved.Offset); err == nil && sum == saved.Fingerprint {
+			f.offset = saved.Offset
+		}
+	case !ok && t.started:
+		// A file created after starting is read from the start
+	case !ok && !t.fromStart:
+		f.offset = info.Size()
+	}
+	return nil
+}
+
+// read writes the complete lines after the offset. A trailing partial line is only written if final is true.
+func (t *tailer) read(f *follower, final bool) {
+	buffer := make([]byte, maxLineSize)
+	for {
+		n, err := f.file.ReadAt(buffer, f.offset)
+		data := buffer[:n]
+		consumed := 0
+		for {
+			index := bytes.IndexByte(data[consumed:], '\n')
+			if index < 0 {
+				break
+			}
+			t.write(f, string(data[consumed:consumed+index]), f.offset+int64(consumed))
+			consumed += index + 1
+		}
+		if consumed == 0 && (n == len(buffer) || (final && n > 0)) {
+			// A line that's too long or the partial last line of a rotated file
+			t.write(f, string(data), f.offset)
+			consumed = n
+		}
+		f.offset += int64(consumed)
+		if consumed == 0 {
+			if err != nil && err != io.EOF {
+				emitError("error reading `%s`: %s", f.path, err)
+			}
+			return
+		}
+	}
+}
+
+// write writes a line that starts at the offset start of the file.
+func (t *tailer) write(f *follower, line string, start int64) {
+	line = strings.TrimSuffix(line, "\r")
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	message, level, claims := t.extractor.extract(line)
+	claims = append(claims, fileClaim(f.path))
+	result := service.LogCaller("", 0, "", false, message, claims, level, service.LogTime())
+	t.pending = append(t.pending, pendingEntry{follower: f, file: f.file, start: start, result: result})
+}
+
+// checkpoint waits for pending entries to be written then saves the positions of the files if any changed.
+// A file's position is only saved up to the first entry that failed to be written. The file is read again from that entry
+// on the next poll so entries are written at least once.
+func (t *tailer) checkpoint() error {
+	failed := map[*follower]bool{}
+	for _, entry := range t.pending {
+		if failed[entry.follower] {
+			continue
+		}
+		if _, err := entry.result.Result(); err != nil {
+			emitError("error writing to alt4: %s", err)
+			failed[entry.follower] = true
+			if entry.follower.file == entry.file {
+				entry.follower.offset = entry.start
+			} else {
+				emitError("lines of `%s` written before it was rotated are lost", entry.follower.path)
+			}
+		}
+	}
+	t.pending = nil
+	for path, f := range t.followers {
+		if f.file == nil {
+			continue
+		}
+		sum, err := fingerprint(f.file, f.offset)
+		if err != nil {
+			return err
+		}
+		t.checkpoints.set(path, position{Offset: f.offset, Fingerprint: sum})
+	}
+	return t.checkpoints.save()
+}
+
+// close closes the followed files.
+func (t *tailer) close() {
+	for _, f := range t.followers {
+		if f.file != nil {
+			_ = f.file.Close()
+		}
+	}
+}
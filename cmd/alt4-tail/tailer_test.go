@@ -0,0 +1,235 @@
+package main
+
+import (
+	"github.com/alt4dev/go/alt4test"
+	"github.com/alt4dev/go/log"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func setUp(t *testing.T) (*alt4test.Recorder, string) {
+	service.SetMode(service.ModeRelease)
+	helper := alt4test.Record()
+	dir, err := ioutil.TempDir("", "alt4-tail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return helper, dir
+}
+
+func appendTo(t *testing.T, path string, content string) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err = file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectMessages(t *testing.T, helper *alt4test.Recorder, expected ...string) {
+	t.Helper()
+	// Entries are written asynchronously but poll waits for them so the order is only guaranteed between polls
+	found := map[string]int{}
+	var messages []string
+	for _, msg := range helper.Reset() {
+		messages = append(messages, msg.Message)
+		found[msg.Message]++
+	}
+	if len(messages) != len(expected) {
+		t.Errorf("Expected %v. Found %v", expected, messages)
+		return
+	}
+	for _, message := range expected {
+		if found[message] == 0 {
+			t.Errorf("Expected %v. Found %v", expected, messages)
+			return
+		}
+		found[message]--
+	}
+}
+
+func TestTailer(t *testing.T) {
+	helper, dir := setUp(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	appendTo(t, path, "old line\n")
+
+	c, _ := loadCheckpoints(filepath.Join(dir, "checkpoint.json"))
+	tail := newTailer([]string{filepath.Join(dir, "*.log")}, false, &extractor{defaultLevel: proto.Log_INFO}, c)
+	defer tail.close()
+	if err := tail.poll(); err != nil {
+		t.Fatal(err)
+	}
+	expectMessages(t, helper)
+
+	appendTo(t, path, "first\nsecond\npart")
+	_ = tail.poll()
+	expectMessages(t, helper, "first", "second")
+	appendTo(t, path, "ial\n")
+	_ = tail.poll()
+	expectMessages(t, helper, "partial")
+
+	// Truncated in place
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendTo(t, path, "after truncate\n")
+	_ = tail.poll()
+	expectMessages(t, helper, "after truncate")
+
+	// Rotated by renaming. Lines written to the old file before the new file is created aren't lost.
+	writer, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Rename(path, filepath.Join(dir, "app.log.1")); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = writer.WriteString("late line\nno newline")
+	_ = writer.Close()
+	appendTo(t, path, "new file\n")
+	_ = tail.poll()
+	expectMessages(t, helper, "late line", "no newline")
+	_ = tail.poll()
+	expectMessages(t, helper, "new file")
+
+	// Files created after starting are read from the start
+	appendTo(t, filepath.Join(dir, "other.log"), "other file\n")
+	_ = tail.poll()
+	expectMessages(t, helper, "other file")
+
+	// Removed files stop being followed
+	_ = os.Remove(filepath.Join(dir, "other.log"))
+	_ = tail.poll()
+	if _, ok := tail.followers[filepath.Join(dir, "other.log")]; ok {
+		t.Error("Expected the removed file to stop being followed")
+	}
+}
+
+func TestTailer_Checkpoint(t *testing.T) {
+	helper, dir := setUp(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	checkpointPath := filepath.Join(dir, "checkpoint.json")
+	appendTo(t, path, "first\n")
+
+	c, _ := loadCheckpoints(checkpointPath)
+	tail := newTailer([]string{path}, true, &extractor{defaultLevel: proto.Log_INFO}, c)
+	_ = tail.poll()
+	tail.close()
+	expectMessages(t, helper, "first")
+
+	// Lines written while stopped are read once after a restart
+	appendTo(t, path, "while stopped\n")
+	c, err := loadCheckpoints(checkpointPath)
+	if err != nil || c.positions[path].Offset != int64(len("first\n")) {
+		t.Fatalf("Expected the position to be saved. Found %v %v", c.positions, err)
+	}
+	tail = newTailer([]string{path}, true, &extractor{defaultLevel: proto.Log_INFO}, c)
+	_ = tail.poll()
+	tail.close()
+	expectMessages(t, helper, "while stopped")
+
+	// A different file at the same path is read from the start
+	_ = os.Remove(path)
+	appendTo(t, path, "replaced file with more content\n")
+	c, _ = loadCheckpoints(checkpointPath)
+	tail = newTailer([]string{path}, false, &extractor{defaultLevel: proto.Log_INFO}, c)
+	_ = tail.poll()
+	tail.close()
+	expectMessages(t, helper, "replaced file with more content")
+}
+
+func TestTailer_CheckpointUnchanged(t *testing.T) {
+	helper, dir := setUp(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	checkpointPath := filepath.Join(dir, "checkpoint.json")
+	appendTo(t, path, "first\n")
+
+	c, _ := loadCheckpoints(checkpointPath)
+	tail := newTailer([]string{path}, true, &extractor{defaultLevel: proto.Log_INFO}, c)
+	defer tail.close()
+	_ = tail.poll()
+	expectMessages(t, helper, "first")
+
+	// Polls without new lines don't write the checkpoint file
+	if err := os.Remove(checkpointPath); err != nil {
+		t.Fatal(err)
+	}
+	_ = tail.poll()
+	if _, err := os.Stat(checkpointPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the checkpoint to be saved only when a position changes. Found %v", err)
+	}
+	appendTo(t, path, "second\n")
+	_ = tail.poll()
+	expectMessages(t, helper, "second")
+	if c, err := loadCheckpoints(checkpointPath); err != nil || c.positions[path].Offset != int64(len("first\nsecond\n")) {
+		t.Errorf("Expected the new position to be saved. Found %v %v", c, err)
+	}
+}
+
+func TestTailer_CheckpointFailedWrite(t *testing.T) {
+	helper, dir := setUp(t)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	checkpointPath := filepath.Join(dir, "checkpoint.json")
+	appendTo(t, path, "first\nsecond\nthird\n")
+
+	helper.Fail("second")
+	c, _ := loadCheckpoints(checkpointPath)
+	tail := newTailer([]string{path}, true, &extractor{defaultLevel: proto.Log_INFO}, c)
+	_ = tail.poll()
+	tail.close()
+	expectMessages(t, helper, "first", "third")
+
+	// The position is saved up to the last entry written before the failure
+	c, err := loadCheckpoints(checkpointPath)
+	if err != nil || c.positions[path].Offset != int64(len("first\n")) {
+		t.Fatalf("Expected the position before the failed entry to be saved. Found %v %v", c.positions, err)
+	}
+
+	// The failed entry is written after a restart
+	helper.Fail("")
+	tail = newTailer([]string{path}, true, &extractor{defaultLevel: proto.Log_INFO}, c)
+	_ = tail.poll()
+	tail.close()
+	expectMessages(t, helper, "second", "third")
+}
+
+func TestExtractor(t *testing.T) {
+	e := &extractor{
+		patterns:     []*regexp.Regexp{regexp.MustCompile(`^(?P<time>\S+) (?P<level>[A-Z]+) \[(?P<component>[^\]]+)\] (?P<message>.*)$`)},
+		levelRules:   service.DefaultLevelRules,
+		defaultLevel: proto.Log_INFO,
+		claims:       log.Claims{"env": "prod"},
+	}
+	message, level, claims := e.extract("2021-03-01T10:00:00Z WARN [db] pool exhausted")
+	if message != "pool exhausted" || level != proto.Log_WARNING {
+		t.Errorf("Unexpected entry %s %s", message, level)
+	}
+	found := map[string]string{}
+	for _, claim := range claims {
+		found[claim.Name] = claim.Value
+	}
+	if len(found) != 3 || found["component"] != "db" || found["time"] != "2021-03-01T10:00:00Z" || found["env"] != "prod" {
+		t.Errorf("Unexpected claims %v", found)
+	}
+
+	// Lines that don't match fall back to level markers
+	message, level, _ = e.extract("[ERROR] disk full")
+	if message != "disk full" || level != proto.Log_ERROR {
+		t.Errorf("Unexpected entry %s %s", message, level)
+	}
+	message, level, _ = e.extract("plain line")
+	if message != "plain line" || level != proto.Log_INFO {
+		t.Errorf("Unexpected entry %s %s", message, level)
+	}
+}
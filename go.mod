@@ -6,5 +6,7 @@ require (
 	github.com/alt4dev/protobuff v1.0.7
 	github.com/google/go-cmp v0.5.1 // indirect
 	github.com/google/uuid v1.1.1
+	github.com/sirupsen/logrus v1.8.1
+	go.uber.org/zap v1.16.0
 	google.golang.org/grpc v1.32.0
 )
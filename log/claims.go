@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"github.com/alt4dev/go/service"
 	"github.com/alt4dev/protobuff/proto"
-	"time"
 )
 
 // Claims are fields that will can be associated to your log entry.
@@ -22,6 +21,25 @@ func (claims Claims) Group(v ...interface{}) *GroupResult {
 	}
 }
 
+// JoinGroup start a log group for the goroutine that continues the thread `threadId` e.g. a thread id propagated from another service.
+// A group should be closed after. Use: `defer Claims{...}.JoinGroup(threadId, ...).Close()`
+func (claims Claims) JoinGroup(threadId string, v ...interface{}) *GroupResult {
+	t := service.LogTime()
+	title := fmt.Sprint(v...)
+	return &GroupResult{
+		logResult: service.JoinGroup(2, threadId, title, claims.parse(), proto.Log_NONE, t),
+		claims: &claims,
+	}
+}
+
+// Log send claims and the log message to alt4 with the provided log level. Log message will be formatted by fmt.Sprint(a...)
+// This is useful for integrations where the level is only known at runtime.
+func (claims Claims) Log(level proto.Log_Level, v ...interface{}) *service.LogResult {
+	t := service.LogTime()
+	message := fmt.Sprint(v...)
+	return service.Log(2, false, message, claims.parse(), level, t)
+}
+
 // Print send claims and the log message to alt4. The log level is NONE. Log message will be formatted by fmt.Sprint(a...)
 func (claims Claims) Print(v ...interface{}) *service.LogResult {
 	t := service.LogTime()
@@ -181,36 +199,16 @@ func (claims Claims) Panicln(v ...interface{}) {
 	BuiltInPanic(message)
 }
 
+// ProtoClaims converts claims to the claims sent to alt4.
+// This is useful for integrations that write logs using the `service` package directly.
+func (claims Claims) ProtoClaims() []*proto.Claim {
+	return claims.parse()
+}
+
 func (claims Claims) parse() []*proto.Claim {
 	protoClaims := make([]*proto.Claim, 0)
 	for key, i := range claims {
-		var claimValue string
-		var claimType proto.Claim_Type
-		switch i.(type) {
-		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
-			claimType = proto.Claim_NUMBER
-			claimValue = fmt.Sprint(i)
-		case float32, float64:
-			claimType = proto.Claim_NUMBER
-			claimValue = fmt.Sprint(i)
-		case bool:
-			claimType = proto.Claim_BOOLEAN
-			claimValue = fmt.Sprint(i.(bool))
-		case string:
-			claimType = proto.Claim_STRING
-			claimValue = i.(string)
-		case time.Time:
-			claimType = proto.Claim_TIMESTAMP
-			claimValue = fmt.Sprint(i.(time.Time).UnixNano())
-		default:
-			claimType = proto.Claim_STRING
-			claimValue = fmt.Sprint(i)
-		}
-		protoClaims = append(protoClaims, &proto.Claim{
-			Name:     key,
-			Type: claimType,
-			Value:    claimValue,
-		})
+		protoClaims = appendClaim(protoClaims, key, i, 1, nil)
 	}
-	return protoClaims
+	return checkSchemas(protoClaims)
 }
@@ -0,0 +1,29 @@
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ClaimsFlag is a flag.Value that adds claims passed as `name=value` to the claims it wraps. The flag can be repeated.
+// Example: claims := log.Claims{}; flag.Var(log.ClaimsFlag(claims), "claim", "Claim added to every entry as name=value")
+type ClaimsFlag Claims
+
+func (claims ClaimsFlag) String() string {
+	pairs := make([]string, 0, len(claims))
+	for name, value := range claims {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", name, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (claims ClaimsFlag) Set(value string) error {
+	index := strings.Index(value, "=")
+	if index <= 0 {
+		return fmt.Errorf("expected a claim as name=value, found `%s`", value)
+	}
+	claims[value[:index]] = value[index+1:]
+	return nil
+}
@@ -0,0 +1,26 @@
+package log
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+)
+
+func TestClaimsFlag(t *testing.T) {
+	claims := Claims{}
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	flags.Var(ClaimsFlag(claims), "claim", "")
+	if err := flags.Parse([]string{"-claim", "env=prod", "-claim", "query=a=b"}); err != nil {
+		t.Fatal(err)
+	}
+	if claims["env"] != "prod" || claims["query"] != "a=b" {
+		t.Errorf("Unexpected claims %v", claims)
+	}
+	if ClaimsFlag(claims).String() != "env=prod,query=a=b" {
+		t.Errorf("Unexpected string %s", ClaimsFlag(claims).String())
+	}
+	if err := flags.Parse([]string{"-claim", "=value"}); err == nil {
+		t.Error("Expected claims without a name to be rejected")
+	}
+}
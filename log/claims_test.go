@@ -2,6 +2,7 @@ package log
 
 import (
 	"fmt"
+	"github.com/alt4dev/go/service"
 	"github.com/alt4dev/protobuff/proto"
 	"testing"
 )
@@ -122,3 +123,21 @@ func TestClaims_Panic(t *testing.T) {
 	testClaims.Panicf("A test formatting, here's a number %d", 145)
 }
 
+
+func TestClaims_JoinGroup(t *testing.T) {
+	setUp(t, proto.Log_NONE, testClaims.parse())
+	testMessage = fmt.Sprint("A test print testMessage", "nothing", 10)
+	testLine = whereAmI() + 1
+	group := testClaims.JoinGroup("thread-from-another-service", "A test print testMessage", "nothing", 10)
+	defer group.Close()
+	if threadId, _ := service.ThreadId(); threadId != "thread-from-another-service" {
+		t.Errorf("Expected the group to continue the thread provided. Found '%s'", threadId)
+	}
+}
+
+func TestClaims_Log(t *testing.T) {
+	setUp(t, proto.Log_WARNING, testClaims.parse())
+	testMessage = fmt.Sprint("A test print testMessage", "nothing", 10)
+	testLine = whereAmI() + 1
+	_, _ = testClaims.Log(proto.Log_WARNING, "A test print testMessage", "nothing", 10).Result()
+}
@@ -0,0 +1,89 @@
+package log
+
+import (
+	"fmt"
+	"github.com/alt4dev/protobuff/proto"
+	"reflect"
+	"strconv"
+)
+
+// ErrorClaim the name of the claim set by Err.
+const ErrorClaim = "error"
+
+// maxErrorChain the number of causes of an error written as claims.
+const maxErrorChain = 16
+
+// Claimer is implemented by errors that carry claims of their own e.g. the id of a failed request.
+// The claims are written under the name of the error claim, e.g. `error.request_id`.
+type Claimer interface {
+	Alt4Claims() Claims
+}
+
+// Err creates claims for an error. Use: `log.Err(err).Error("Saving order failed")`
+// See Claims for how errors are written.
+func Err(err error) Claims {
+	return Claims{ErrorClaim: err}
+}
+
+// appendError appends the claims of an error:
+// `name` the message, `name.type` the concrete type, `name.stack` the innermost stack trace attached,
+// `name.cause.N` and `name.cause.N.type` each cause found with errors.Unwrap,
+// `name.errors.N` the errors joined in an error implementing `Unwrap() []error` e.g. errors.Join,
+// and the claims of errors implementing Claimer.
+func appendError(claims []*proto.Claim, name string, err error, depth int, visiting map[uintptr]bool) []*proto.Claim {
+	claims = append(claims,
+		&proto.Claim{Name: name, Type: proto.Claim_STRING, Value: err.Error()},
+		&proto.Claim{Name: name + ".type", Type: proto.Claim_STRING, Value: fmt.Sprintf("%T", err)},
+	)
+	stack := ""
+	for index := 0; err != nil && index <= maxErrorChain; index++ {
+		if index > 0 {
+			prefix := name + ".cause." + strconv.Itoa(index-1)
+			claims = append(claims,
+				&proto.Claim{Name: prefix, Type: proto.Claim_STRING, Value: err.Error()},
+				&proto.Claim{Name: prefix + ".type", Type: proto.Claim_STRING, Value: fmt.Sprintf("%T", err)},
+			)
+		}
+		if claimer, ok := err.(Claimer); ok && depth < MaxClaimDepth {
+			for key, value := range claimer.Alt4Claims() {
+				claims = appendClaim(claims, name+"."+key, value, depth+1, visiting)
+			}
+		}
+		// The innermost stack trace is the closest to where the error occurred
+		if trace := stackTrace(err); trace != "" {
+			stack = trace
+		}
+		switch wrapped := err.(type) {
+		case interface{ Unwrap() []error }:
+			if depth < MaxClaimDepth {
+				for i, member := range wrapped.Unwrap() {
+					if member != nil {
+						claims = appendError(claims, name+".errors."+strconv.Itoa(i), member, depth+1, visiting)
+					}
+				}
+			}
+			err = nil
+		case interface{ Unwrap() error }:
+			err = wrapped.Unwrap()
+		default:
+			err = nil
+		}
+	}
+	if stack != "" {
+		claims = append(claims, &proto.Claim{Name: name + ".stack", Type: proto.Claim_STRING, Value: stack})
+	}
+	return claims
+}
+
+// stackTrace formats the stack trace of errors with a `StackTrace()` method e.g. errors created by github.com/pkg/errors.
+func stackTrace(err error) string {
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return ""
+	}
+	trace := method.Call(nil)[0].Interface()
+	if s, ok := trace.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%+v", trace)
+}
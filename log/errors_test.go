@@ -0,0 +1,89 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alt4dev/protobuff/proto"
+	"testing"
+)
+
+type requestError struct {
+	requestId string
+	err       error
+}
+
+func (e *requestError) Error() string {
+	return "request " + e.requestId + ": " + e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+func (e *requestError) Alt4Claims() Claims {
+	return Claims{"request_id": e.requestId, "retryable": true}
+}
+
+func (e *requestError) StackTrace() string {
+	return "outer stack"
+}
+
+type stackError struct {
+	message string
+}
+
+func (e stackError) Error() string {
+	return e.message
+}
+
+func (e stackError) StackTrace() []string {
+	return []string{"main.main", "main.go:10"}
+}
+
+type joinedError []error
+
+func (e joinedError) Error() string {
+	return fmt.Sprint([]error(e))
+}
+
+func (e joinedError) Unwrap() []error {
+	return e
+}
+
+func TestErr(t *testing.T) {
+	root := stackError{"connection refused"}
+	err := fmt.Errorf("saving order: %w", &requestError{requestId: "r-1", err: root})
+	expectClaims(t, Err(err).parse(), map[string]claim{
+		"error":              {proto.Claim_STRING, "saving order: request r-1: connection refused"},
+		"error.type":         {proto.Claim_STRING, "*fmt.wrapError"},
+		"error.cause.0":      {proto.Claim_STRING, "request r-1: connection refused"},
+		"error.cause.0.type": {proto.Claim_STRING, "*log.requestError"},
+		"error.cause.1":      {proto.Claim_STRING, "connection refused"},
+		"error.cause.1.type": {proto.Claim_STRING, "log.stackError"},
+		"error.request_id":   {proto.Claim_STRING, "r-1"},
+		"error.retryable":    {proto.Claim_BOOLEAN, "true"},
+		"error.stack":        {proto.Claim_STRING, "[main.main main.go:10]"},
+	})
+}
+
+func TestErr_Joined(t *testing.T) {
+	err := joinedError{errors.New("disk full"), nil, &requestError{requestId: "r-2", err: errors.New("timeout")}}
+	expectClaims(t, Claims{"failure": err}.parse(), map[string]claim{
+		"failure":                       {proto.Claim_STRING, "[disk full <nil> request r-2: timeout]"},
+		"failure.type":                  {proto.Claim_STRING, "log.joinedError"},
+		"failure.errors.0":              {proto.Claim_STRING, "disk full"},
+		"failure.errors.0.type":         {proto.Claim_STRING, "*errors.errorString"},
+		"failure.errors.2":              {proto.Claim_STRING, "request r-2: timeout"},
+		"failure.errors.2.type":         {proto.Claim_STRING, "*log.requestError"},
+		"failure.errors.2.cause.0":      {proto.Claim_STRING, "timeout"},
+		"failure.errors.2.cause.0.type": {proto.Claim_STRING, "*errors.errorString"},
+		"failure.errors.2.request_id":   {proto.Claim_STRING, "r-2"},
+		"failure.errors.2.retryable":    {proto.Claim_BOOLEAN, "true"},
+		"failure.errors.2.stack":        {proto.Claim_STRING, "outer stack"},
+	})
+
+	var typedNil *requestError
+	expectClaims(t, Claims{"error": typedNil}.parse(), map[string]claim{
+		"error": {proto.Claim_STRING, "<nil>"},
+	})
+}
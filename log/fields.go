@@ -0,0 +1,161 @@
+package log
+
+import (
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Field is a typed claim. Unlike Claims, fields are converted to claims without reflection or map iteration.
+// Use: `log.Infow("Order placed", log.String("order_id", id), log.Int("items", n))`
+type Field struct {
+	name      string
+	claimType proto.Claim_Type
+	value     string
+	// unit written as the claim `name.unit` e.g. for durations
+	unit string
+	// any is set by Any and converted like a value in Claims
+	any    interface{}
+	hasAny bool
+}
+
+// Fields are typed claims.
+type Fields []Field
+
+// String creates a STRING claim.
+func String(name string, value string) Field {
+	return Field{name: name, claimType: proto.Claim_STRING, value: value}
+}
+
+// Int creates a NUMBER claim.
+func Int(name string, value int) Field {
+	return Field{name: name, claimType: proto.Claim_NUMBER, value: strconv.Itoa(value)}
+}
+
+// Int64 creates a NUMBER claim.
+func Int64(name string, value int64) Field {
+	return Field{name: name, claimType: proto.Claim_NUMBER, value: strconv.FormatInt(value, 10)}
+}
+
+// Uint64 creates a NUMBER claim.
+func Uint64(name string, value uint64) Field {
+	return Field{name: name, claimType: proto.Claim_NUMBER, value: strconv.FormatUint(value, 10)}
+}
+
+// Float64 creates a NUMBER claim. NaN and infinite values are written as strings.
+func Float64(name string, value float64) Field {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return Field{name: name, claimType: proto.Claim_STRING, value: strconv.FormatFloat(value, 'g', -1, 64)}
+	}
+	return Field{name: name, claimType: proto.Claim_NUMBER, value: strconv.FormatFloat(value, 'g', -1, 64)}
+}
+
+// Bool creates a BOOLEAN claim.
+func Bool(name string, value bool) Field {
+	return Field{name: name, claimType: proto.Claim_BOOLEAN, value: strconv.FormatBool(value)}
+}
+
+// Time creates a TIMESTAMP claim.
+func Time(name string, value time.Time) Field {
+	return Field{name: name, claimType: proto.Claim_TIMESTAMP, value: strconv.FormatInt(value.UnixNano(), 10)}
+}
+
+// Duration creates a NUMBER claim in nanoseconds and the claim `name.unit`.
+func Duration(name string, value time.Duration) Field {
+	return Field{
+		name:      name,
+		claimType: proto.Claim_NUMBER,
+		value:     strconv.FormatInt(int64(value), 10),
+		unit:      DurationUnit,
+	}
+}
+
+// Any creates claims for any value the same way as Claims e.g. errors, maps and structs.
+// It uses reflection, prefer the typed constructors in hot paths.
+func Any(name string, value interface{}) Field {
+	return Field{name: name, any: value, hasAny: true}
+}
+
+// ProtoClaims converts the fields to the claims written to alt4.
+// The claims of typed fields are allocated together.
+func (fields Fields) ProtoClaims() []*proto.Claim {
+	size := 0
+	for _, field := range fields {
+		if field.unit != "" {
+			size++
+		}
+		if !field.hasAny {
+			size++
+		}
+	}
+	backing := make([]proto.Claim, size)
+	claims := make([]*proto.Claim, 0, len(fields)+size)
+	for _, field := range fields {
+		if field.hasAny {
+			claims = appendClaim(claims, field.name, field.any, 1, nil)
+			continue
+		}
+		claim := &backing[0]
+		claim.Name, claim.Type, claim.Value = field.name, field.claimType, field.value
+		backing = backing[1:]
+		claims = append(claims, claim)
+		if field.unit != "" {
+			claim = &backing[0]
+			claim.Name, claim.Type, claim.Value = field.name+".unit", proto.Claim_STRING, field.unit
+			backing = backing[1:]
+			claims = append(claims, claim)
+		}
+	}
+	return checkSchemas(claims)
+}
+
+// Groupw start a log group with fields for the goroutine that calls this function.
+// A group should be closed after. Use: `defer log.Groupw("Processing order", log.String("order_id", id)).Close()`
+func Groupw(title string, fields ...Field) *GroupResult {
+	t := service.LogTime()
+	claims := Fields(fields).ProtoClaims()
+	return &GroupResult{
+		logResult:   service.Log(2, true, title, claims, proto.Log_NONE, t),
+		protoClaims: claims,
+	}
+}
+
+// Logw send a log message with fields to alt4 with the provided log level.
+func Logw(level proto.Log_Level, message string, fields ...Field) *service.LogResult {
+	t := service.LogTime()
+	return service.Log(2, false, message, Fields(fields).ProtoClaims(), level, t)
+}
+
+// Debugw send a log message with fields to alt4. The log level is DEBUG.
+func Debugw(message string, fields ...Field) *service.LogResult {
+	t := service.LogTime()
+	return service.Log(2, false, message, Fields(fields).ProtoClaims(), proto.Log_DEBUG, t)
+}
+
+// Infow send a log message with fields to alt4. The log level is INFO.
+func Infow(message string, fields ...Field) *service.LogResult {
+	t := service.LogTime()
+	return service.Log(2, false, message, Fields(fields).ProtoClaims(), proto.Log_INFO, t)
+}
+
+// Warningw send a log message with fields to alt4. The log level is WARNING.
+func Warningw(message string, fields ...Field) *service.LogResult {
+	t := service.LogTime()
+	return service.Log(2, false, message, Fields(fields).ProtoClaims(), proto.Log_WARNING, t)
+}
+
+// Errorw send a log message with fields to alt4. The log level is ERROR.
+func Errorw(message string, fields ...Field) *service.LogResult {
+	t := service.LogTime()
+	return service.Log(2, false, message, Fields(fields).ProtoClaims(), proto.Log_ERROR, t)
+}
+
+// Fatalw send a log message with fields to alt4 and exit. The log level is FATAL.
+// The function waits for the log to be written before exiting.
+func Fatalw(message string, fields ...Field) {
+	t := service.LogTime()
+	_, _ = service.Log(2, false, message, Fields(fields).ProtoClaims(), proto.Log_FATAL, t).Result()
+	BuiltInExit(1)
+}
@@ -0,0 +1,116 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alt4dev/protobuff/proto"
+	"math"
+	"testing"
+	"time"
+)
+
+var testFields = []Field{String("name", "Tester"), Int("age", 25), Bool("notStupid", false)}
+
+func TestFields_ProtoClaims(t *testing.T) {
+	created := time.Unix(1600000000, 0)
+	fields := Fields{
+		String("name", "Tester"),
+		Int("age", 25),
+		Int64("id", -7),
+		Uint64("size", 1<<40),
+		Float64("ratio", 0.25),
+		Float64("infinite", math.Inf(1)),
+		Bool("admin", true),
+		Time("created", created),
+		Duration("latency", 2*time.Second),
+		Any("error", errors.New("timeout")),
+	}
+	expectClaims(t, fields.ProtoClaims(), map[string]claim{
+		"name":         {proto.Claim_STRING, "Tester"},
+		"age":          {proto.Claim_NUMBER, "25"},
+		"id":           {proto.Claim_NUMBER, "-7"},
+		"size":         {proto.Claim_NUMBER, "1099511627776"},
+		"ratio":        {proto.Claim_NUMBER, "0.25"},
+		"infinite":     {proto.Claim_STRING, "+Inf"},
+		"admin":        {proto.Claim_BOOLEAN, "true"},
+		"created":      {proto.Claim_TIMESTAMP, "1600000000000000000"},
+		"latency":      {proto.Claim_NUMBER, "2000000000"},
+		"latency.unit": {proto.Claim_STRING, "ns"},
+		"error":        {proto.Claim_STRING, "timeout"},
+		"error.type":   {proto.Claim_STRING, "*errors.errorString"},
+	})
+
+	// The same claims as Claims
+	if fmt.Sprint(claimsByName(Fields(testFields).ProtoClaims())) != fmt.Sprint(claimsByName(testClaims.parse())) {
+		t.Error("Expected fields to create the same claims as Claims")
+	}
+}
+
+func TestGroupw(t *testing.T) {
+	setUp(t, proto.Log_NONE, testClaims.parse())
+	testMessage = "A test group"
+	testLine = whereAmI() + 1
+	defer Groupw("A test group", testFields...).Close()
+}
+
+func TestInfow(t *testing.T) {
+	setUp(t, proto.Log_INFO, testClaims.parse())
+	testMessage = "A test message"
+	testLine = whereAmI() + 1
+	_, _ = Infow("A test message", testFields...).Result()
+
+	setUp(t, proto.Log_DEBUG, testClaims.parse())
+	testLine = whereAmI() + 1
+	_, _ = Debugw("A test message", testFields...).Result()
+
+	setUp(t, proto.Log_WARNING, testClaims.parse())
+	testLine = whereAmI() + 1
+	_, _ = Warningw("A test message", testFields...).Result()
+
+	setUp(t, proto.Log_ERROR, testClaims.parse())
+	testLine = whereAmI() + 1
+	_, _ = Errorw("A test message", testFields...).Result()
+
+	setUp(t, proto.Log_ERROR, testClaims.parse())
+	testLine = whereAmI() + 1
+	_, _ = Logw(proto.Log_ERROR, "A test message", testFields...).Result()
+
+	setUp(t, proto.Log_FATAL, testClaims.parse())
+	exited := false
+	BuiltInExit = func(code int) {
+		exited = code == 1
+	}
+	testLine = whereAmI() + 1
+	Fatalw("A test message", testFields...)
+	if !exited {
+		t.Error("Expected Fatalw to exit")
+	}
+}
+
+func BenchmarkClaims_parse(b *testing.B) {
+	created := time.Unix(1600000000, 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Claims{
+			"name":    "Tester",
+			"age":     25,
+			"admin":   true,
+			"ratio":   0.25,
+			"created": created,
+		}.parse()
+	}
+}
+
+func BenchmarkFields_ProtoClaims(b *testing.B) {
+	created := time.Unix(1600000000, 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Fields{
+			String("name", "Tester"),
+			Int("age", 25),
+			Bool("admin", true),
+			Float64("ratio", 0.25),
+			Time("created", created),
+		}.ProtoClaims()
+	}
+}
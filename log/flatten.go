@@ -0,0 +1,239 @@
+package log
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"github.com/alt4dev/protobuff/proto"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxClaimDepth the number of levels maps, structs and slices in claims are flattened to e.g. `user.address.city` has 3 levels.
+// Values nested deeper are written as JSON strings.
+var MaxClaimDepth = 5
+
+// CycleValue the value of a claim whose value contains itself.
+const CycleValue = "<cycle>"
+
+// NilValue the value of claims whose value is nil.
+const NilValue = "<nil>"
+
+// DurationUnit the unit durations are written in, the nanoseconds of time.Duration. The unit is written as the claim `name.unit`.
+const DurationUnit = "ns"
+
+// appendClaim appends the claims for the value i. Maps, structs and slices are flattened into claims named `name.key`, `name.field` and `name.index`.
+// visiting holds the maps, slices and pointers being flattened to detect cycles.
+func appendClaim(claims []*proto.Claim, name string, i interface{}, depth int, visiting map[uintptr]bool) []*proto.Claim {
+	if i == nil || isNil(i) {
+		return append(claims, &proto.Claim{Name: name, Type: proto.Claim_STRING, Value: NilValue})
+	}
+	if v := reflect.ValueOf(i); v.Kind() == reflect.Ptr && !hasPointerMethods(v.Type()) {
+		// Pointers are written as the values they point to
+		return appendNested(claims, name, v.Pointer(), func(visiting map[uintptr]bool) []*proto.Claim {
+			return appendClaim(claims, name, v.Elem().Interface(), depth, visiting)
+		}, visiting)
+	}
+	if err, ok := i.(error); ok {
+		return appendError(claims, name, err, depth, visiting)
+	}
+	if d, ok := i.(time.Duration); ok {
+		return append(claims,
+			&proto.Claim{Name: name, Type: proto.Claim_NUMBER, Value: strconv.FormatInt(int64(d), 10)},
+			&proto.Claim{Name: name + ".unit", Type: proto.Claim_STRING, Value: DurationUnit},
+		)
+	}
+	if claimType, value, ok := leafClaim(i); ok {
+		return append(claims, &proto.Claim{Name: name, Type: claimType, Value: value})
+	}
+
+	v := reflect.ValueOf(i)
+	switch v.Kind() {
+	case reflect.Ptr:
+		return appendNested(claims, name, v.Pointer(), func(visiting map[uintptr]bool) []*proto.Claim {
+			return appendClaim(claims, name, v.Elem().Interface(), depth, visiting)
+		}, visiting)
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return append(claims, &proto.Claim{Name: name, Type: proto.Claim_STRING, Value: string(v.Bytes())})
+		}
+		if depth >= MaxClaimDepth {
+			return append(claims, &proto.Claim{Name: name, Type: proto.Claim_STRING, Value: jsonValue(i)})
+		}
+		var pointer uintptr
+		if v.Kind() == reflect.Map || v.Kind() == reflect.Slice {
+			pointer = v.Pointer()
+		}
+		flattened := appendNested(claims, name, pointer, func(visiting map[uintptr]bool) []*proto.Claim {
+			return appendChildren(claims, name, v, depth, visiting)
+		}, visiting)
+		if v.Kind() == reflect.Struct && len(flattened) == len(claims) {
+			// Structs without exported fields
+			return append(claims, &proto.Claim{Name: name, Type: proto.Claim_STRING, Value: fmt.Sprint(i)})
+		}
+		return flattened
+	}
+	return append(claims, &proto.Claim{Name: name, Type: proto.Claim_STRING, Value: fmt.Sprint(i)})
+}
+
+// appendNested calls flatten unless pointer is already being flattened.
+func appendNested(claims []*proto.Claim, name string, pointer uintptr, flatten func(visiting map[uintptr]bool) []*proto.Claim, visiting map[uintptr]bool) []*proto.Claim {
+	if pointer == 0 {
+		return flatten(visiting)
+	}
+	if visiting[pointer] {
+		return append(claims, &proto.Claim{Name: name, Type: proto.Claim_STRING, Value: CycleValue})
+	}
+	if visiting == nil {
+		visiting = map[uintptr]bool{}
+	}
+	visiting[pointer] = true
+	defer delete(visiting, pointer)
+	return flatten(visiting)
+}
+
+// appendChildren appends the claims of the keys, fields or elements of a map, struct or slice.
+func appendChildren(claims []*proto.Claim, name string, v reflect.Value, depth int, visiting map[uintptr]bool) []*proto.Claim {
+	switch v.Kind() {
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		values := map[string]reflect.Value{}
+		iter := v.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			keys = append(keys, key)
+			values[key] = iter.Value()
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			claims = appendClaim(claims, name+"."+key, values[key].Interface(), depth+1, visiting)
+		}
+	case reflect.Slice, reflect.Array:
+		for index := 0; index < v.Len(); index++ {
+			claims = appendClaim(claims, name+"."+strconv.Itoa(index), v.Index(index).Interface(), depth+1, visiting)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for index := 0; index < t.NumField(); index++ {
+			field := t.Field(index)
+			if field.PkgPath != "" && !field.Anonymous {
+				// Unexported
+				continue
+			}
+			fieldName := field.Name
+			if tag, ok := field.Tag.Lookup("json"); ok {
+				tagName := strings.Split(tag, ",")[0]
+				if tagName == "-" {
+					continue
+				}
+				if tagName != "" {
+					fieldName = tagName
+				}
+			}
+			value := v.Field(index)
+			if field.Anonymous {
+				// Fields of embedded structs are flattened as fields of the struct embedding them
+				if value.Kind() == reflect.Ptr {
+					if value.IsNil() {
+						continue
+					}
+					value = value.Elem()
+				}
+				if value.Kind() == reflect.Struct {
+					claims = appendChildren(claims, name, value, depth, visiting)
+					continue
+				}
+				if field.PkgPath != "" {
+					continue
+				}
+			}
+			claims = appendClaim(claims, name+"."+fieldName, value.Interface(), depth+1, visiting)
+		}
+	}
+	return claims
+}
+
+var describers = []reflect.Type{
+	reflect.TypeOf((*error)(nil)).Elem(),
+	reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem(),
+	reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+	reflect.TypeOf((*json.Marshaler)(nil)).Elem(),
+}
+
+// hasPointerMethods is true if the pointer type t describes itself with methods that the type it points to doesn't have.
+func hasPointerMethods(t reflect.Type) bool {
+	for _, describer := range describers {
+		if t.Implements(describer) && !t.Elem().Implements(describer) {
+			return true
+		}
+	}
+	return false
+}
+
+// leafClaim returns the type and value of values that aren't flattened.
+// Named types e.g. enums without a String method are typed by their underlying kind.
+func leafClaim(i interface{}) (claimType proto.Claim_Type, value string, ok bool) {
+	switch v := i.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return proto.Claim_NUMBER, fmt.Sprint(i), true
+	case float32, float64:
+		return proto.Claim_NUMBER, fmt.Sprint(i), true
+	case bool:
+		return proto.Claim_BOOLEAN, fmt.Sprint(v), true
+	case string:
+		return proto.Claim_STRING, v, true
+	case time.Time:
+		return proto.Claim_TIMESTAMP, fmt.Sprint(v.UnixNano()), true
+	}
+
+	// Values that describe themselves aren't flattened. Enums with a String method e.g. time.Month are written by name.
+	switch v := i.(type) {
+	case encoding.TextMarshaler:
+		if text, err := v.MarshalText(); err == nil {
+			return proto.Claim_STRING, string(text), true
+		}
+	case fmt.Stringer:
+		return proto.Claim_STRING, v.String(), true
+	case json.Marshaler:
+		if content, err := v.MarshalJSON(); err == nil {
+			return proto.Claim_STRING, string(content), true
+		}
+	}
+
+	rv := reflect.ValueOf(i)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return proto.Claim_NUMBER, strconv.FormatInt(rv.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return proto.Claim_NUMBER, strconv.FormatUint(rv.Uint(), 10), true
+	case reflect.Float32, reflect.Float64:
+		return proto.Claim_NUMBER, strconv.FormatFloat(rv.Float(), 'g', -1, rv.Type().Bits()), true
+	case reflect.Bool:
+		return proto.Claim_BOOLEAN, strconv.FormatBool(rv.Bool()), true
+	case reflect.String:
+		return proto.Claim_STRING, rv.String(), true
+	}
+	return proto.Claim_STRING, "", false
+}
+
+// isNil is true for nil pointers, maps, slices e.t.c. stored in an interface
+func isNil(i interface{}) bool {
+	v := reflect.ValueOf(i)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// jsonValue encodes values nested beyond MaxClaimDepth.
+func jsonValue(i interface{}) string {
+	content, err := json.Marshal(i)
+	if err != nil {
+		return fmt.Sprintf("%T", i)
+	}
+	return string(content)
+}
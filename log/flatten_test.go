@@ -0,0 +1,213 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alt4dev/protobuff/proto"
+	"net"
+	"testing"
+	"time"
+)
+
+type claim struct {
+	Type  proto.Claim_Type
+	Value string
+}
+
+func claimsByName(claims []*proto.Claim) map[string]claim {
+	found := map[string]claim{}
+	for _, c := range claims {
+		found[c.Name] = claim{c.Type, c.Value}
+	}
+	return found
+}
+
+func expectClaims(t *testing.T, claims []*proto.Claim, expected map[string]claim) {
+	t.Helper()
+	found := claimsByName(claims)
+	if len(found) != len(expected) {
+		t.Errorf("Expected %d claims. Found %v", len(expected), found)
+	}
+	for name, c := range expected {
+		if found[name] != c {
+			t.Errorf("Expected the claim %s=%v. Found %v", name, c, found[name])
+		}
+	}
+}
+
+type address struct {
+	City    string `json:"city"`
+	Zip     int
+	Ignored string `json:"-"`
+}
+
+type base struct {
+	Id int64 `json:"id"`
+}
+
+type hidden struct {
+	Visible string
+}
+
+type customer struct {
+	base
+	*hidden
+	Name     string
+	Address  *address
+	Tags     []string
+	Joined   time.Time
+	password string
+}
+
+func TestClaims_Flatten(t *testing.T) {
+	joined := time.Unix(1600000000, 0)
+	claims := Claims{
+		"user": customer{
+			base:     base{Id: 7},
+			hidden:   &hidden{Visible: "yes"},
+			Name:     "Jane",
+			Address:  &address{City: "Nairobi", Zip: 100},
+			Tags:     []string{"vip", "beta"},
+			Joined:   joined,
+			password: "secret",
+		},
+		"items": []map[string]interface{}{{"sku": "A1", "qty": 2}, {"sku": "B2", "gift": true}},
+		"empty": map[string]int{},
+		"raw":   []byte("bytes"),
+		"err":   errors.New("timeout"),
+	}
+	expectClaims(t, claims.parse(), map[string]claim{
+		"user.id":           {proto.Claim_NUMBER, "7"},
+		"user.Visible":      {proto.Claim_STRING, "yes"},
+		"user.Name":         {proto.Claim_STRING, "Jane"},
+		"user.Address.city": {proto.Claim_STRING, "Nairobi"},
+		"user.Address.Zip":  {proto.Claim_NUMBER, "100"},
+		"user.Tags.0":       {proto.Claim_STRING, "vip"},
+		"user.Tags.1":       {proto.Claim_STRING, "beta"},
+		"user.Joined":       {proto.Claim_TIMESTAMP, "1600000000000000000"},
+		"items.0.sku":       {proto.Claim_STRING, "A1"},
+		"items.0.qty":       {proto.Claim_NUMBER, "2"},
+		"items.1.sku":       {proto.Claim_STRING, "B2"},
+		"items.1.gift":      {proto.Claim_BOOLEAN, "true"},
+		"raw":               {proto.Claim_STRING, "bytes"},
+		"err":               {proto.Claim_STRING, "timeout"},
+		"err.type":          {proto.Claim_STRING, "*errors.errorString"},
+	})
+}
+
+func TestClaims_FlattenDepth(t *testing.T) {
+	depth := MaxClaimDepth
+	defer func() {
+		MaxClaimDepth = depth
+	}()
+	MaxClaimDepth = 2
+	claims := Claims{"a": map[string]interface{}{"b": map[string]interface{}{"c": 1}, "d": 2}}
+	expectClaims(t, claims.parse(), map[string]claim{
+		"a.b": {proto.Claim_STRING, `{"c":1}`},
+		"a.d": {proto.Claim_NUMBER, "2"},
+	})
+}
+
+type node struct {
+	Name string
+	Next *node
+}
+
+func TestClaims_FlattenCycle(t *testing.T) {
+	first := &node{Name: "first"}
+	first.Next = &node{Name: "second", Next: first}
+	cyclic := map[string]interface{}{"name": "map"}
+	cyclic["self"] = cyclic
+	claims := Claims{"list": first, "map": cyclic}
+	expectClaims(t, claims.parse(), map[string]claim{
+		"list.Name":      {proto.Claim_STRING, "first"},
+		"list.Next.Name": {proto.Claim_STRING, "second"},
+		"list.Next.Next": {proto.Claim_STRING, CycleValue},
+		"map.name":       {proto.Claim_STRING, "map"},
+		"map.self":       {proto.Claim_STRING, CycleValue},
+	})
+}
+
+type status int
+
+func (s status) String() string {
+	return "active"
+}
+
+// priority is an enum without a String method
+type priority int
+
+type ratio float32
+
+type color string
+
+type point struct {
+	X, Y int
+}
+
+func (p point) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d,%d", p.X, p.Y)), nil
+}
+
+type version struct {
+	Major int
+}
+
+func (v *version) String() string {
+	return fmt.Sprintf("v%d", v.Major)
+}
+
+type payload struct {
+	body string
+}
+
+func (p payload) MarshalJSON() ([]byte, error) {
+	return []byte(`{"body":"` + p.body + `"}`), nil
+}
+
+func TestClaims_Types(t *testing.T) {
+	created := time.Unix(1600000000, 0)
+	var nilTime *time.Time
+	var nilMap map[string]int
+	count := 3
+	claims := Claims{
+		"latency":  1500 * time.Microsecond,
+		"created":  &created,
+		"missing":  nilTime,
+		"nothing":  nil,
+		"none":     nilMap,
+		"count":    &count,
+		"status":   status(1),
+		"ratio":    ratio(0.5),
+		"color":    color("red"),
+		"point":    point{1, 2},
+		"version":  &version{2},
+		"payload":  payload{"hi"},
+		"ip":       net.IPv4(10, 0, 0, 1),
+		"interval": &struct{ Every time.Duration }{time.Second},
+		"month":    time.March,
+		"level":    proto.Log_WARNING,
+		"priority": priority(2),
+	}
+	expectClaims(t, claims.parse(), map[string]claim{
+		"latency":             {proto.Claim_NUMBER, "1500000"},
+		"latency.unit":        {proto.Claim_STRING, "ns"},
+		"created":             {proto.Claim_TIMESTAMP, "1600000000000000000"},
+		"missing":             {proto.Claim_STRING, NilValue},
+		"nothing":             {proto.Claim_STRING, NilValue},
+		"none":                {proto.Claim_STRING, NilValue},
+		"count":               {proto.Claim_NUMBER, "3"},
+		"status":              {proto.Claim_STRING, "active"},
+		"month":               {proto.Claim_STRING, "March"},
+		"level":               {proto.Claim_STRING, "WARNING"},
+		"priority":            {proto.Claim_NUMBER, "2"},
+		"ratio":               {proto.Claim_NUMBER, "0.5"},
+		"color":               {proto.Claim_STRING, "red"},
+		"point":               {proto.Claim_STRING, "1,2"},
+		"version":             {proto.Claim_STRING, "v2"},
+		"payload":             {proto.Claim_STRING, `{"body":"hi"}`},
+		"ip":                  {proto.Claim_STRING, "10.0.0.1"},
+		"interval.Every":      {proto.Claim_NUMBER, "1000000000"},
+		"interval.Every.unit": {proto.Claim_STRING, "ns"},
+	})
+}
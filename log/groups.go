@@ -11,6 +11,8 @@ import (
 type GroupResult struct {
 	logResult *service.LogResult
 	claims *Claims
+	// protoClaims are used when the group was created with fields
+	protoClaims []*proto.Claim
 }
 
 // Return the result of the actual log event
@@ -29,6 +31,8 @@ func (result GroupResult) Close(v ...interface{}) {
 	var claims []*proto.Claim = nil
 	if result.claims != nil {
 		claims = result.claims.parse()
+	} else if result.protoClaims != nil {
+		claims = result.protoClaims
 	}
 	// Recover any panic, just to losg it and continue panakin.
 	if r := recover(); r != nil {
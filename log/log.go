@@ -32,6 +32,17 @@ func Group(v ...interface{}) *GroupResult {
 	}
 }
 
+// JoinGroup start a log group for the goroutine that continues the thread `threadId` e.g. a thread id propagated from another service.
+// A group should be closed after. Use: `defer JoinGroup(threadId, ...).Close()`
+func JoinGroup(threadId string, v ...interface{}) *GroupResult {
+	t := service.LogTime()
+	title := fmt.Sprint(v...)
+	return &GroupResult{
+		logResult: service.JoinGroup(2, threadId, title, nil, proto.Log_NONE, t),
+		claims: nil,
+	}
+}
+
 // Print send a log message to alt4. The log level is NONE. Log message will be formatted by fmt.Sprint(a...)
 func Print(v ...interface{}) *service.LogResult {
 	t := service.LogTime()
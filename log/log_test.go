@@ -78,6 +78,16 @@ func TestGroup(t *testing.T) {
 	defer Group("A test print testMessage", "nothing", 10).Close()
 }
 
+func TestJoinGroup(t *testing.T) {
+	setUp(t, proto.Log_NONE, nil)
+	testMessage = fmt.Sprint("A test print testMessage", "nothing", 10)
+	testLine = whereAmI() + 1
+	defer JoinGroup("thread-from-another-service", "A test print testMessage", "nothing", 10).Close()
+	if threadId, _ := service.ThreadId(); threadId != "thread-from-another-service" {
+		t.Errorf("Expected the group to continue the thread provided. Found '%s'", threadId)
+	}
+}
+
 func TestPrint(t *testing.T) {
 	setUp(t, proto.Log_NONE, nil)
 	testMessage = fmt.Sprint("A test print testMessage", "nothing", 10)
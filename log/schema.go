@@ -0,0 +1,184 @@
+package log
+
+import (
+	"fmt"
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClaimSchema declares what a claim should look like so that the same claim has the same type everywhere.
+type ClaimSchema struct {
+	// Type the type of the claim. Values of other types are converted when it's safe e.g. the string "42" to a NUMBER.
+	Type proto.Claim_Type
+	// Required the claim must be in every entry with claims. It's checked once the claims of an entry are complete
+	// i.e. including claims added by claim providers, so claims can be created in parts e.g. base claims.
+	Required bool
+	// Allowed the values the claim can have. Any value is allowed if empty.
+	Allowed []string
+}
+
+// Violation describes a claim that doesn't match its schema.
+type Violation struct {
+	Claim  string
+	Reason string
+}
+
+func (violation Violation) Error() string {
+	return fmt.Sprintf("claim `%s` %s", violation.Claim, violation.Reason)
+}
+
+var schemaLock sync.RWMutex
+var schemas = map[string]ClaimSchema{}
+var violationHandler = reportViolation
+
+// RegisterClaim declares the schema of a claim. Claims are checked against their schema when they're created,
+// and violations are reported in the debug and testing modes. Use SetViolationHandler to handle violations differently.
+// Example: log.RegisterClaim("user_id", log.ClaimSchema{Type: proto.Claim_NUMBER, Required: true})
+func RegisterClaim(name string, schema ClaimSchema) {
+	schemaLock.Lock()
+	defer schemaLock.Unlock()
+	schemas[name] = schema
+}
+
+// UnregisterClaim removes the schema of a claim.
+func UnregisterClaim(name string) {
+	schemaLock.Lock()
+	defer schemaLock.Unlock()
+	delete(schemas, name)
+}
+
+// SetViolationHandler sets the function called for each claim that doesn't match its schema and returns the previous handler.
+// A nil handler restores the default handler which emits a warning in the debug and testing modes.
+func SetViolationHandler(handler func(violation Violation)) (previous func(violation Violation)) {
+	if handler == nil {
+		handler = reportViolation
+	}
+	schemaLock.Lock()
+	defer schemaLock.Unlock()
+	previous, violationHandler = violationHandler, handler
+	return previous
+}
+
+func reportViolation(violation Violation) {
+	service.EmitWarning(violation.Error())
+}
+
+// checkSchemas checks claims against the registered schemas and converts values to the declared type where it's safe.
+// Required claims are checked by checkRequired once an entry is complete.
+func checkSchemas(claims []*proto.Claim) []*proto.Claim {
+	schemaLock.RLock()
+	if len(schemas) == 0 {
+		schemaLock.RUnlock()
+		return claims
+	}
+	var violations []Violation
+	for _, claim := range claims {
+		schema, ok := schemas[claim.Name]
+		if !ok {
+			continue
+		}
+		if claim.Type != schema.Type {
+			value, converted := convert(claim.Value, claim.Type, schema.Type)
+			if !converted {
+				violations = append(violations, Violation{claim.Name, fmt.Sprintf("is a %s, expected a %s", claim.Type, schema.Type)})
+			} else {
+				claim.Type, claim.Value = schema.Type, value
+			}
+		}
+		if len(schema.Allowed) > 0 && !contains(schema.Allowed, claim.Value) {
+			violations = append(violations, Violation{claim.Name, fmt.Sprintf("has the value `%s`, expected one of %s", claim.Value, strings.Join(schema.Allowed, ", "))})
+		}
+	}
+	handler := violationHandler
+	schemaLock.RUnlock()
+
+	for _, violation := range violations {
+		handler(violation)
+	}
+	return claims
+}
+
+func init() {
+	service.SetClaimsCheck(checkRequired)
+}
+
+// checkRequired checks that the claims of an entry include the required claims. Entries without claims aren't checked.
+func checkRequired(claims []*proto.Claim) {
+	if len(claims) == 0 {
+		return
+	}
+	schemaLock.RLock()
+	if len(schemas) == 0 {
+		schemaLock.RUnlock()
+		return
+	}
+	found := make(map[string]bool, len(claims))
+	for _, claim := range claims {
+		found[claim.Name] = true
+	}
+	var violations []Violation
+	for name, schema := range schemas {
+		if schema.Required && !found[name] {
+			violations = append(violations, Violation{name, "is required"})
+		}
+	}
+	handler := violationHandler
+	schemaLock.RUnlock()
+
+	for _, violation := range violations {
+		handler(violation)
+	}
+}
+
+// decimalNumber matches numbers in decimal notation e.g. `42`, `-1.5`, `2e10`
+var decimalNumber = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// convert converts a claim value to another type if no information is lost.
+func convert(value string, from proto.Claim_Type, to proto.Claim_Type) (string, bool) {
+	switch to {
+	case proto.Claim_STRING:
+		if from == proto.Claim_TIMESTAMP {
+			nanoseconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return value, false
+			}
+			return time.Unix(0, nanoseconds).UTC().Format(time.RFC3339Nano), true
+		}
+		return value, true
+	case proto.Claim_NUMBER:
+		// ParseFloat also accepts NaN, infinities and hex floats which aren't numbers alt4 can query
+		if trimmed := strings.TrimSpace(value); from == proto.Claim_STRING && decimalNumber.MatchString(trimmed) {
+			if f, err := strconv.ParseFloat(trimmed, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+				return trimmed, true
+			}
+		}
+	case proto.Claim_BOOLEAN:
+		if from == proto.Claim_STRING {
+			if b, err := strconv.ParseBool(strings.TrimSpace(value)); err == nil {
+				return strconv.FormatBool(b), true
+			}
+		}
+	case proto.Claim_TIMESTAMP:
+		if from == proto.Claim_STRING {
+			if t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(value)); err == nil {
+				return strconv.FormatInt(t.UnixNano(), 10), true
+			}
+		}
+	}
+	return value, false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package log
+
+import (
+	"github.com/alt4dev/go/service"
+	"github.com/alt4dev/protobuff/proto"
+	"sort"
+	"testing"
+	"time"
+)
+
+func withSchemas(t *testing.T, claims map[string]ClaimSchema) *[]string {
+	var violations []string
+	for name, schema := range claims {
+		RegisterClaim(name, schema)
+	}
+	previous := SetViolationHandler(func(violation Violation) {
+		violations = append(violations, violation.Error())
+	})
+	t.Cleanup(func() {
+		for name := range claims {
+			UnregisterClaim(name)
+		}
+		SetViolationHandler(previous)
+	})
+	return &violations
+}
+
+func TestClaims_Schema(t *testing.T) {
+	violations := withSchemas(t, map[string]ClaimSchema{
+		"user_id":    {Type: proto.Claim_NUMBER, Required: true},
+		"admin":      {Type: proto.Claim_BOOLEAN},
+		"created":    {Type: proto.Claim_TIMESTAMP},
+		"code":       {Type: proto.Claim_STRING},
+		"plan":       {Type: proto.Claim_STRING, Allowed: []string{"free", "pro"}},
+		"request_id": {Type: proto.Claim_STRING, Required: true},
+	})
+	created := time.Unix(1600000000, 0).UTC()
+	claims := Claims{
+		"user_id": "42",
+		"admin":   "true",
+		"created": created.Format(time.RFC3339),
+		"code":    404,
+		"plan":    "enterprise",
+	}.parse()
+	expectClaims(t, claims, map[string]claim{
+		"user_id": {proto.Claim_NUMBER, "42"},
+		"admin":   {proto.Claim_BOOLEAN, "true"},
+		"created": {proto.Claim_TIMESTAMP, "1600000000000000000"},
+		"code":    {proto.Claim_STRING, "404"},
+		"plan":    {proto.Claim_STRING, "enterprise"},
+	})
+	sort.Strings(*violations)
+	// Required claims aren't checked until the entry is complete
+	expected := []string{
+		"claim `plan` has the value `enterprise`, expected one of free, pro",
+	}
+	if len(*violations) != len(expected) {
+		t.Fatalf("Expected violations %v, got %v", expected, *violations)
+	}
+	for i := range expected {
+		if (*violations)[i] != expected[i] {
+			t.Errorf("Expected violation `%s`, got `%s`", expected[i], (*violations)[i])
+		}
+	}
+}
+
+func TestClaims_SchemaUnsafe(t *testing.T) {
+	violations := withSchemas(t, map[string]ClaimSchema{
+		"user_id": {Type: proto.Claim_NUMBER},
+		"admin":   {Type: proto.Claim_BOOLEAN},
+	})
+	claims := Fields{String("user_id", "anonymous"), Int("admin", 1)}.ProtoClaims()
+	// Values that can't be converted are kept as is
+	expectClaims(t, claims, map[string]claim{
+		"user_id": {proto.Claim_STRING, "anonymous"},
+		"admin":   {proto.Claim_NUMBER, "1"},
+	})
+	if len(*violations) != 2 {
+		t.Errorf("Expected 2 violations, got %v", *violations)
+	}
+
+}
+
+func TestClaims_SchemaRequired(t *testing.T) {
+	violations := withSchemas(t, map[string]ClaimSchema{
+		"request_id": {Type: proto.Claim_STRING, Required: true},
+	})
+	// Claims created in parts aren't checked
+	Fields{String("name", "Tester")}.ProtoClaims()
+	Claims{"age": 25}.parse()
+	if len(*violations) != 0 {
+		t.Errorf("Expected no violations, got %v", *violations)
+	}
+
+	// Claims added by claim providers are part of the entry
+	remove := service.AddClaimProvider(func(entry service.Entry) []*proto.Claim {
+		return Fields{String("request_id", "abc")}.ProtoClaims()
+	})
+	setUp(t, proto.Log_INFO, append(testClaims.parse(), Fields{String("request_id", "abc")}.ProtoClaims()...))
+	testMessage = "A test print testMessage"
+	testLine = whereAmI() + 1
+	_, _ = testClaims.Info("A test print testMessage").Result()
+	remove()
+	if len(*violations) != 0 {
+		t.Errorf("Expected no violations, got %v", *violations)
+	}
+
+	// Complete entries are checked once
+	setUp(t, proto.Log_INFO, testClaims.parse())
+	testLine = whereAmI() + 1
+	_, _ = testClaims.Info("A test print testMessage").Result()
+	expected := "claim `request_id` is required"
+	if len(*violations) != 1 || (*violations)[0] != expected {
+		t.Errorf("Expected violations [%s], got %v", expected, *violations)
+	}
+
+	// Entries without claims aren't checked
+	*violations = nil
+	setUp(t, proto.Log_INFO, nil)
+	testLine = whereAmI() + 1
+	_, _ = Info("A test print testMessage").Result()
+	if len(*violations) != 0 {
+		t.Errorf("Expected no violations, got %v", *violations)
+	}
+}
+
+func TestConvert_Number(t *testing.T) {
+	for value, converted := range map[string]bool{
+		"42":        true,
+		" -1.5 ":    true,
+		"+2e10":     true,
+		".5":        true,
+		"3.":        true,
+		"NaN":       false,
+		"nan":       false,
+		"Inf":       false,
+		"-inf":      false,
+		"infinity":  false,
+		"0x1p-2":    false,
+		"0x10":      false,
+		"1_000":     false,
+		"1e999":     false,
+		"":          false,
+		"12 apples": false,
+	} {
+		result, ok := convert(value, proto.Claim_STRING, proto.Claim_NUMBER)
+		if ok != converted {
+			t.Errorf("Expected `%s` to be converted: %v. Found %q %v", value, converted, result, ok)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package log
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// RedactedValue replaces the values of fields tagged with `redact`.
+const RedactedValue = "REDACTED"
+
+// TagName the struct tag read by ClaimsFrom.
+const TagName = "alt4"
+
+// fieldPlan describes how a struct field becomes a claim.
+type fieldPlan struct {
+	name string
+	// index the path to the field through embedded structs
+	index     []int
+	omitEmpty bool
+	redact    bool
+	// depth the number of embedded structs the field is promoted through. Shallower fields hide deeper fields of the same name.
+	depth int
+}
+
+// plans caches the field plans of each struct type
+var plans sync.Map
+
+// ClaimsFrom creates claims from the exported fields of a struct or a pointer to a struct.
+// Fields are named and configured with the tag `alt4:"name,omitempty,redact"`:
+// `name` the claim name, defaults to the field name. `-` skips the field.
+// `omitempty` skips the field if it has its zero value.
+// `redact` writes RedactedValue instead of the value.
+// Fields of embedded structs are added as if they were fields of the struct, unless the embedded struct is named by a tag.
+// Nested structs with alt4 tags are converted the same way.
+// Use: `log.ClaimsFrom(order).Info("Order placed")`
+func ClaimsFrom(v interface{}) Claims {
+	claims := Claims{}
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return claims
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return claims
+	}
+	for _, plan := range structPlan(value.Type()) {
+		field, ok := fieldByIndex(value, plan.index)
+		if !ok || (plan.omitEmpty && field.IsZero()) || !field.CanInterface() {
+			continue
+		}
+		switch {
+		case plan.redact:
+			claims[plan.name] = RedactedValue
+		case hasTags(field.Type()) && !(field.Kind() == reflect.Ptr && field.IsNil()):
+			claims[plan.name] = ClaimsFrom(field.Interface())
+		default:
+			claims[plan.name] = field.Interface()
+		}
+	}
+	return claims
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex but ok is false instead of panicking on nil embedded pointers.
+func fieldByIndex(value reflect.Value, index []int) (field reflect.Value, ok bool) {
+	for i, fieldIndex := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return value, false
+			}
+			value = value.Elem()
+		}
+		value = value.Field(fieldIndex)
+	}
+	return value, true
+}
+
+// hasTags is true for structs and pointers to structs with alt4 tags.
+func hasTags(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if _, ok := t.Field(i).Tag.Lookup(TagName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func structPlan(t reflect.Type) []fieldPlan {
+	if cached, ok := plans.Load(t); ok {
+		return cached.([]fieldPlan)
+	}
+	byName := map[string]fieldPlan{}
+	names := make([]string, 0)
+	addFields(t, nil, 0, byName, &names, map[reflect.Type]bool{})
+	plan := make([]fieldPlan, 0, len(names))
+	for _, name := range names {
+		plan = append(plan, byName[name])
+	}
+	plans.Store(t, plan)
+	return plan
+}
+
+// addFields adds the plans of the fields of t. Names are kept in the order the fields are declared.
+func addFields(t reflect.Type, index []int, depth int, byName map[string]fieldPlan, names *[]string, visiting map[reflect.Type]bool) {
+	if visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, tagged := field.Tag.Lookup(TagName)
+		options := strings.Split(tag, ",")
+		if options[0] == "-" && len(options) == 1 {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && fieldType.Kind() == reflect.Struct && (!tagged || options[0] == "") {
+			// Fields of embedded structs are promoted, including exported fields of unexported embedded structs
+			addFields(fieldType, fieldIndex, depth+1, byName, names, visiting)
+			continue
+		}
+		if field.PkgPath != "" {
+			// Unexported
+			continue
+		}
+
+		plan := fieldPlan{name: field.Name, index: fieldIndex, depth: depth}
+		if options[0] != "" {
+			plan.name = options[0]
+		}
+		for _, option := range options[1:] {
+			switch strings.TrimSpace(option) {
+			case "omitempty":
+				plan.omitEmpty = true
+			case "redact":
+				plan.redact = true
+			}
+		}
+		existing, ok := byName[plan.name]
+		if !ok {
+			*names = append(*names, plan.name)
+		}
+		if !ok || plan.depth < existing.depth {
+			byName[plan.name] = plan
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package log
+
+import (
+	"github.com/alt4dev/protobuff/proto"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type audit struct {
+	CreatedBy string    `alt4:"created_by"`
+	CreatedAt time.Time `alt4:"created_at,omitempty"`
+}
+
+type Tenant struct {
+	Id string `alt4:"id"`
+}
+
+type shipping struct {
+	City string `alt4:"city"`
+	Note string `alt4:"-"`
+}
+
+type order struct {
+	audit
+	*Tenant  `alt4:"tenant"`
+	Id       string  `alt4:"order_id"`
+	Total    float64 `alt4:"total"`
+	Coupon   string  `alt4:"coupon,omitempty"`
+	Card     string  `alt4:"card,redact"`
+	Shipping *shipping
+	Billing  *shipping `alt4:"billing"`
+	Items    int
+	Internal string `alt4:"-"`
+	secret   string
+}
+
+func TestClaimsFrom(t *testing.T) {
+	o := &order{
+		audit:    audit{CreatedBy: "jane"},
+		Tenant:   &Tenant{Id: "acme"},
+		Id:       "o-1",
+		Total:    9.5,
+		Card:     "4111111111111111",
+		Shipping: &shipping{City: "Nairobi", Note: "leave at door"},
+		Items:    2,
+		Internal: "internal",
+		secret:   "secret",
+	}
+	expectClaims(t, ClaimsFrom(o).parse(), map[string]claim{
+		"created_by":    {proto.Claim_STRING, "jane"},
+		"tenant.id":     {proto.Claim_STRING, "acme"},
+		"order_id":      {proto.Claim_STRING, "o-1"},
+		"total":         {proto.Claim_NUMBER, "9.5"},
+		"card":          {proto.Claim_STRING, RedactedValue},
+		"Shipping.city": {proto.Claim_STRING, "Nairobi"},
+		"billing":       {proto.Claim_STRING, NilValue},
+		"Items":         {proto.Claim_NUMBER, "2"},
+	})
+
+	// The plan is cached per type
+	if _, ok := plans.Load(reflect.TypeOf(order{})); !ok {
+		t.Error("Expected the plan to be cached")
+	}
+	if claims := ClaimsFrom(order{Id: "o-2"}); claims["order_id"] != "o-2" || claims["created_by"] != "" {
+		t.Errorf("Unexpected claims %v", claims)
+	}
+
+	var nilOrder *order
+	if len(ClaimsFrom(nilOrder)) != 0 || len(ClaimsFrom("not a struct")) != 0 {
+		t.Error("Expected no claims")
+	}
+}
+
+type named struct {
+	Name string `alt4:"name"`
+}
+
+type shadowing struct {
+	named
+	Name string `alt4:"name"`
+}
+
+func TestClaimsFrom_Shadowing(t *testing.T) {
+	claims := ClaimsFrom(shadowing{named: named{Name: "inner"}, Name: "outer"})
+	if len(claims) != 1 || claims["name"] != "outer" {
+		t.Errorf("Expected the outer field to hide the embedded field. Found %v", claims)
+	}
+}
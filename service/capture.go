@@ -0,0 +1,202 @@
+package service
+
+import (
+	"errors"
+	"github.com/alt4dev/protobuff/proto"
+	"io"
+	"os"
+	"sync"
+)
+
+// ErrCaptureUnsupported is returned by CaptureStdio on platforms where file descriptors can't be redirected.
+var ErrCaptureUnsupported = errors.New("alt4: capturing stdout and stderr isn't supported on this platform")
+
+// ErrCaptureActive is returned by CaptureStdio if stdout and stderr are already being captured.
+var ErrCaptureActive = errors.New("alt4: stdout and stderr are already being captured")
+
+// CaptureOptions configure CaptureStdio.
+type CaptureOptions struct {
+	// StdoutLevel the level of lines written to stdout. Defaults to INFO.
+	StdoutLevel proto.Log_Level
+	// StderrLevel the level of lines written to stderr. Defaults to WARNING.
+	StderrLevel proto.Log_Level
+	// Claims added to every entry. Entries also have the claim `stream` set to `stdout` or `stderr`.
+	Claims []*proto.Claim
+}
+
+var captureLock sync.Mutex
+var capturing bool
+
+// CaptureStdio redirects the process's stdout and stderr through pipes and writes each line to alt4.
+// Output is still written to the original destinations. Multi-line output like panics is kept in a single entry.
+// alt4's own output is sent to the original destinations while capturing to avoid feedback loops.
+//
+// A crashing process stops before captured output is read so the output of fatal errors e.g. unrecovered panics can't be logged.
+// On Go 1.23 and later it's written to the original stderr with debug.SetCrashOutput, replacing the crash output set before.
+// On earlier versions the stderr file descriptor isn't redirected so that it's still written, and only writes to os.Stderr are captured.
+// Call the returned function to stop capturing, it waits for the captured output to be read and sent for writing.
+// Example: restore, err := service.CaptureStdio(service.CaptureOptions{}); defer restore()
+func CaptureStdio(opts CaptureOptions) (restore func() error, err error) {
+	captureLock.Lock()
+	defer captureLock.Unlock()
+	if capturing {
+		return nil, ErrCaptureActive
+	}
+	if opts.StdoutLevel == proto.Log_NONE {
+		opts.StdoutLevel = proto.Log_INFO
+	}
+	if opts.StderrLevel == proto.Log_NONE {
+		opts.StderrLevel = proto.Log_WARNING
+	}
+
+	emitOutput := options.Writer
+	stdoutFile, stderrFile := os.Stdout, os.Stderr
+	stdout, err := captureFile(os.Stdout, "stdout", opts.StdoutLevel, opts.Claims)
+	if err != nil {
+		return nil, err
+	}
+	var stderr *capture
+	if redirectStderr {
+		stderr, err = captureFile(os.Stderr, "stderr", opts.StderrLevel, opts.Claims)
+	} else {
+		stderr, err = captureVar(&os.Stderr, "stderr", opts.StderrLevel, opts.Claims)
+	}
+	if err == nil {
+		if err = setCrashOutput(stderr.original); err != nil {
+			_ = stderr.restore()
+			stderr.close()
+		}
+	}
+	if err != nil {
+		_ = stdout.restore()
+		stdout.close()
+		return nil, err
+	}
+
+	// Feedback loop protection: alt4's own output would otherwise be captured and logged again
+	switch emitOutput {
+	case stderrFile:
+		setEmitOutput(stderr.original)
+	case stdoutFile:
+		setEmitOutput(stdout.original)
+	}
+	capturing = true
+
+	return func() error {
+		captureLock.Lock()
+		defer captureLock.Unlock()
+		if !capturing {
+			return nil
+		}
+		err := stdout.restore()
+		if _err := stderr.restore(); err == nil {
+			err = _err
+		}
+		if _err := setCrashOutput(nil); err == nil {
+			err = _err
+		}
+		setEmitOutput(options.Writer)
+		stdout.close()
+		stderr.close()
+		capturing = false
+		return err
+	}, nil
+}
+
+// capture copies what's written to a redirected file to the original destination and to alt4.
+type capture struct {
+	original *os.File
+	// ownsOriginal whether original is a duplicate of the file descriptor that's closed with the capture
+	ownsOriginal bool
+	// release points the output back to original
+	release func() error
+	reader  *os.File
+	writer  *os.File
+	lines   *LineWriter
+	done    chan struct{}
+}
+
+// captureFile redirects the file descriptor of file.
+func captureFile(file *os.File, stream string, level proto.Log_Level, claims []*proto.Claim) (*capture, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	fd := int(file.Fd())
+	original, err := redirect(fd, int(writer.Fd()))
+	if err != nil {
+		_ = reader.Close()
+		_ = writer.Close()
+		return nil, err
+	}
+	release := func() error {
+		return restoreFd(original, fd)
+	}
+	return newCapture(original, true, release, reader, writer, stream, level, claims), nil
+}
+
+// captureVar replaces the file a variable such as os.Stderr points to. Output written to the file descriptor directly isn't captured.
+func captureVar(file **os.File, stream string, level proto.Log_Level, claims []*proto.Claim) (*capture, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	original := *file
+	*file = writer
+	release := func() error {
+		*file = original
+		return nil
+	}
+	return newCapture(original, false, release, reader, writer, stream, level, claims), nil
+}
+
+func newCapture(original *os.File, ownsOriginal bool, release func() error, reader, writer *os.File, stream string, level proto.Log_Level, claims []*proto.Claim) *capture {
+	streamClaims := append([]*proto.Claim{{Name: "stream", Type: proto.Claim_STRING, Value: stream}}, claims...)
+	c := &capture{
+		original:     original,
+		ownsOriginal: ownsOriginal,
+		release:      release,
+		reader:       reader,
+		writer:       writer,
+		lines: NewLineWriter(NewWriter(WriterOptions{CallDepth: -1, DefaultLevel: level, Claims: streamClaims}), LineWriterOptions{
+			MergeContinuations: true,
+		}),
+		done: make(chan struct{}),
+	}
+	go c.copy()
+	return c
+}
+
+func (c *capture) copy() {
+	defer close(c.done)
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := c.reader.Read(buffer)
+		if n > 0 {
+			_, _ = c.original.Write(buffer[:n])
+			_, _ = c.lines.Write(buffer[:n])
+		}
+		if err == io.EOF || err != nil {
+			return
+		}
+	}
+}
+
+// restore points the output back to the original destination and waits for the captured output to be written.
+func (c *capture) restore() error {
+	err := c.release()
+	// Closing the write end of the pipe lets copy read what's left and stop
+	_ = c.writer.Close()
+	<-c.done
+	if _err := c.lines.Close(); err == nil {
+		err = _err
+	}
+	return err
+}
+
+func (c *capture) close() {
+	_ = c.reader.Close()
+	if c.ownsOriginal {
+		_ = c.original.Close()
+	}
+}
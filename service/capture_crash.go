@@ -0,0 +1,17 @@
+//go:build go1.23
+// +build go1.23
+
+package service
+
+import (
+	"os"
+	"runtime/debug"
+)
+
+// redirectStderr the stderr file descriptor is redirected since the output of fatal errors can still reach the original stderr.
+const redirectStderr = true
+
+// setCrashOutput sends the output of fatal errors to file as well as stderr. A nil file stops it.
+func setCrashOutput(file *os.File) error {
+	return debug.SetCrashOutput(file, debug.CrashOptions{})
+}
@@ -0,0 +1,13 @@
+//go:build !go1.23
+// +build !go1.23
+
+package service
+
+import "os"
+
+// redirectStderr the stderr file descriptor isn't redirected since the output of fatal errors would be lost.
+const redirectStderr = false
+
+func setCrashOutput(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,8 @@
+package service
+
+import "syscall"
+
+// dup2 is implemented with dup3 since some architectures e.g. arm64 don't have dup2.
+func dup2(oldfd int, newfd int) error {
+	return syscall.Dup3(oldfd, newfd, 0)
+}
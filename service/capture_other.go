@@ -0,0 +1,14 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package service
+
+import "os"
+
+func redirect(fd int, target int) (*os.File, error) {
+	return nil, ErrCaptureUnsupported
+}
+
+func restoreFd(original *os.File, fd int) error {
+	return ErrCaptureUnsupported
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/alt4dev/protobuff/proto"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCaptureStdio(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "dragonfly", "freebsd", "linux", "netbsd", "openbsd":
+	default:
+		if _, err := CaptureStdio(CaptureOptions{}); err != ErrCaptureUnsupported {
+			t.Errorf("Expected capturing to be unsupported. Found %v", err)
+		}
+		return
+	}
+	defer releaseMode()()
+	Alt4RemoteHelper = remoteHelperMock{}
+	var lock sync.Mutex
+	var logged []*proto.Log
+	writeMock = func(msg *proto.Log) {
+		lock.Lock()
+		defer lock.Unlock()
+		logged = append(logged, msg)
+	}
+	find := func(message string) *proto.Log {
+		lock.Lock()
+		defer lock.Unlock()
+		for _, msg := range logged {
+			if msg.Message == message {
+				return msg
+			}
+		}
+		return nil
+	}
+	waitFor := func(message string) *proto.Log {
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if msg := find(message); msg != nil {
+				return msg
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return nil
+	}
+
+	restore, err := CaptureStdio(CaptureOptions{StderrLevel: proto.Log_ERROR, Claims: []*proto.Claim{{Name: "job", Value: "backup"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = CaptureStdio(CaptureOptions{}); err != ErrCaptureActive {
+		t.Errorf("Expected a second capture to fail. Found %v", err)
+	}
+	fmt.Println("captured stdout line")
+	_, _ = fmt.Fprintln(os.Stderr, "captured stderr line")
+	_, _ = fmt.Fprintln(os.Stderr, "\tat main.main()")
+	// alt4's own output must not be captured
+	emitWarning.Println("alt4 warning")
+	if err = restore(); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println("not captured")
+
+	stdout := waitFor("captured stdout line")
+	if stdout == nil || stdout.Level != proto.Log_INFO {
+		t.Fatalf("Expected the stdout line at INFO. Found %v", stdout)
+	}
+	if len(stdout.Claims) != 2 || stdout.Claims[0].Name != "stream" || stdout.Claims[0].Value != "stdout" || stdout.Claims[1].Name != "job" {
+		t.Errorf("Expected the stream and base claims. Found %v", stdout.Claims)
+	}
+	// Continuation lines are merged into a single entry
+	stderr := waitFor("captured stderr line\n\tat main.main()")
+	if stderr == nil || stderr.Level != proto.Log_ERROR || stderr.Claims[0].Value != "stderr" {
+		t.Errorf("Expected the stderr lines in one entry at ERROR. Found %v", stderr)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	for _, msg := range logged {
+		if strings.Contains(msg.Message, "alt4 warning") || msg.Message == "not captured" {
+			t.Errorf("Unexpected entry %v", msg)
+		}
+	}
+}
+
+func TestCaptureStdioPanic(t *testing.T) {
+	switch runtime.GOOS {
+	case "darwin", "dragonfly", "freebsd", "linux", "netbsd", "openbsd":
+	default:
+		t.Skip("capturing isn't supported")
+	}
+	if os.Getenv("ALT4_CAPTURE_PANIC") == "1" {
+		releaseMode()
+		Alt4RemoteHelper = remoteHelperMock{}
+		writeMock = func(msg *proto.Log) {}
+		if _, err := CaptureStdio(CaptureOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		panic("captured panic")
+	}
+
+	// The panic crashes a child process with stdout and stderr captured
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCaptureStdioPanic$")
+	cmd.Env = append(os.Environ(), "ALT4_CAPTURE_PANIC=1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil {
+		t.Fatal("Expected the child process to crash")
+	}
+	if !strings.Contains(stderr.String(), "panic: captured panic") || !strings.Contains(stderr.String(), "goroutine ") {
+		t.Errorf("Expected the panic and its stack trace on the original stderr. Found %q", stderr.String())
+	}
+}
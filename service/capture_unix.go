@@ -0,0 +1,28 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package service
+
+import (
+	"os"
+	"syscall"
+)
+
+// redirect points fd to target. A file for the original destination of fd is returned.
+func redirect(fd int, target int) (*os.File, error) {
+	originalFd, err := syscall.Dup(fd)
+	if err != nil {
+		return nil, err
+	}
+	syscall.CloseOnExec(originalFd)
+	if err = dup2(target, fd); err != nil {
+		_ = syscall.Close(originalFd)
+		return nil, err
+	}
+	return os.NewFile(uintptr(originalFd), "original"), nil
+}
+
+// restoreFd points fd back to original.
+func restoreFd(original *os.File, fd int) error {
+	return dup2(int(original.Fd()), fd)
+}
@@ -7,6 +7,7 @@ Code used to write logs to the local machine usually notifying
 */
 
 import (
+	"io"
 	"log"
 )
 
@@ -20,4 +21,19 @@ func init() {
 	emitError = log.New(options.Writer, "[alt4](if seeing this please contact: critical@alt4.dev) ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
 	emitWarning = log.New(options.Writer, "[alt4] WARNING: ", log.Ldate|log.Ltime|log.Lshortfile)
 	emit = log.New(options.Writer, "", 0)
-}
\ No newline at end of file
+}
+
+// setEmitOutput changes where alt4 emits its own output
+func setEmitOutput(w io.Writer) {
+	emitError.SetOutput(w)
+	emitWarning.SetOutput(w)
+	emit.SetOutput(w)
+}
+
+// EmitWarning writes a warning to the debug output in the debug and testing modes.
+// It's used by the log package to report problems that don't stop logs from being written e.g. claims that don't match their schema.
+func EmitWarning(message string) {
+	if options.Mode == ModeDebug || options.Mode == ModeTesting {
+		_ = emitWarning.Output(2, message)
+	}
+}
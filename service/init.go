@@ -123,6 +123,7 @@ func SetSource(source string) {
 // Defaults os.Stderr
 func SetDebugOutput(w io.Writer){
 	options.Writer = w
+	setEmitOutput(w)
 }
 
 var timeLock sync.Mutex
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/alt4dev/protobuff/proto"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONWriterOptions configure a writer created by NewJSONWriter.
+// Keys are tried in order and the first one found is used. Empty keys default to names used by zerolog, zap and logrus.
+type JSONWriterOptions struct {
+	// LevelKeys default: `level`. Levels can be names e.g. `warn` or numbers used by pino and bunyan e.g. 40.
+	LevelKeys []string
+	// MessageKeys default: `message`, `msg`
+	MessageKeys []string
+	// TimeKeys default: `time`, `ts`, `timestamp`. Times can be RFC3339 strings or unix timestamps in seconds, milliseconds, microseconds or nanoseconds.
+	TimeKeys []string
+	// CallerKeys default: `caller`. Callers are expected as `file:line`
+	CallerKeys []string
+	// DefaultLevel the level used for lines without a known level and for plain text lines. Defaults to INFO.
+	DefaultLevel proto.Log_Level
+	// Sync waits for each entry to be written before Write returns.
+	Sync bool
+}
+
+type jsonWriter struct {
+	opts JSONWriterOptions
+}
+
+// NewJSONWriter creates a writer that parses JSON log lines e.g. those produced by zerolog and writes them to alt4.
+// The level, message, time and caller are read into the log entry and the remaining fields are written as claims.
+// Nested objects and arrays are flattened into dotted claim names e.g. `user.id`, `tags.0`
+// Lines that aren't JSON objects are written as plain text.
+// Example: zerolog.New(service.NewJSONWriter(service.JSONWriterOptions{}))
+func NewJSONWriter(opts JSONWriterOptions) io.Writer {
+	if opts.LevelKeys == nil {
+		opts.LevelKeys = []string{"level"}
+	}
+	if opts.MessageKeys == nil {
+		opts.MessageKeys = []string{"message", "msg"}
+	}
+	if opts.TimeKeys == nil {
+		opts.TimeKeys = []string{"time", "ts", "timestamp"}
+	}
+	if opts.CallerKeys == nil {
+		opts.CallerKeys = []string{"caller"}
+	}
+	if opts.DefaultLevel == proto.Log_NONE {
+		opts.DefaultLevel = proto.Log_INFO
+	}
+	return &jsonWriter{opts: opts}
+}
+
+func (writer *jsonWriter) Write(p []byte) (n int, err error) {
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		result := writer.writeLine(line)
+		if writer.opts.Sync {
+			if _, _err := result.Result(); _err != nil {
+				err = _err
+			}
+		}
+	}
+	return len(p), err
+}
+
+func (writer *jsonWriter) writeLine(line []byte) *LogResult {
+	fields := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if line[0] != '{' || decoder.Decode(&fields) != nil {
+		return LogCaller("", 0, "", false, string(line), nil, writer.opts.DefaultLevel, LogTime())
+	}
+
+	level := writer.opts.DefaultLevel
+	if parsed, ok := popLevel(fields, writer.opts.LevelKeys); ok {
+		level = parsed
+	}
+	message, _ := popString(fields, writer.opts.MessageKeys)
+	logTime := LogTime()
+	for _, key := range writer.opts.TimeKeys {
+		if parsed, ok := parseTime(fields[key]); ok {
+			logTime = parsed
+			delete(fields, key)
+			break
+		}
+	}
+	var file string
+	var callerLine int
+	if caller, ok := popString(fields, writer.opts.CallerKeys); ok {
+		file, callerLine = parseCaller(caller)
+	}
+	return LogCaller(file, callerLine, "", false, message, jsonClaims(nil, "", fields), level, logTime)
+}
+
+// ParseLevel maps common level names e.g. `warn`, `ERROR`, `critical` to an alt4 log level.
+func ParseLevel(level string) (proto.Log_Level, bool) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "trace", "debug", "dbg":
+		return proto.Log_DEBUG, true
+	case "info", "information", "notice", "inf":
+		return proto.Log_INFO, true
+	case "warn", "warning", "wrn":
+		return proto.Log_WARNING, true
+	case "error", "err", "eror":
+		return proto.Log_ERROR, true
+	case "fatal", "panic", "dpanic", "critical", "crit", "alert", "emergency", "emerg", "ftl":
+		return proto.Log_FATAL, true
+	}
+	return proto.Log_NONE, false
+}
+
+// popLevel removes the first key found as a string or number and returns the level it maps to.
+// Numbers are levels used by pino and bunyan e.g. 30 for info and 50 for error.
+func popLevel(fields map[string]interface{}, keys []string) (proto.Log_Level, bool) {
+	for _, key := range keys {
+		switch value := fields[key].(type) {
+		case string:
+			delete(fields, key)
+			return ParseLevel(value)
+		case json.Number:
+			delete(fields, key)
+			number, err := value.Float64()
+			if err != nil {
+				return proto.Log_NONE, false
+			}
+			switch {
+			case number >= 60:
+				return proto.Log_FATAL, true
+			case number >= 50:
+				return proto.Log_ERROR, true
+			case number >= 40:
+				return proto.Log_WARNING, true
+			case number >= 30:
+				return proto.Log_INFO, true
+			default:
+				return proto.Log_DEBUG, true
+			}
+		}
+	}
+	return proto.Log_NONE, false
+}
+
+// popString removes and returns the first key found as a string.
+func popString(fields map[string]interface{}, keys []string) (string, bool) {
+	for _, key := range keys {
+		if value, ok := fields[key].(string); ok {
+			delete(fields, key)
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func parseTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, v)
+		return parsed, err == nil
+	case json.Number:
+		// Integers are converted exactly since a float64 can't hold nanosecond timestamps
+		if n, err := v.Int64(); err == nil {
+			abs := n
+			if abs < 0 {
+				abs = -abs
+			}
+			switch {
+			case abs >= 1e17:
+				return time.Unix(0, n), true
+			case abs >= 1e14:
+				return time.Unix(0, n*1e3), true
+			case abs >= 1e11:
+				return time.Unix(0, n*1e6), true
+			default:
+				return time.Unix(n, 0), true
+			}
+		}
+		// Fractional seconds e.g. 1601546400.5
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		// Guess the unit from the magnitude of the timestamp
+		switch abs := math.Abs(f); {
+		case abs >= 1e17:
+			return time.Unix(0, int64(f)), true
+		case abs >= 1e14:
+			return time.Unix(0, int64(f*1e3)), true
+		case abs >= 1e11:
+			return time.Unix(0, int64(f*1e6)), true
+		default:
+			seconds, fraction := math.Modf(f)
+			return time.Unix(int64(seconds), int64(fraction*1e9)), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseCaller splits a caller formatted as `file:line`
+func parseCaller(caller string) (string, int) {
+	index := strings.LastIndex(caller, ":")
+	if index < 0 {
+		return caller, 0
+	}
+	line, err := strconv.Atoi(caller[index+1:])
+	if err != nil {
+		return caller, 0
+	}
+	return caller[:index], line
+}
+
+func jsonClaims(claims []*proto.Claim, prefix string, fields map[string]interface{}) []*proto.Claim {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		claims = appendJSONValue(claims, prefix+key, fields[key])
+	}
+	return claims
+}
+
+func appendJSONValue(claims []*proto.Claim, name string, value interface{}) []*proto.Claim {
+	claim := &proto.Claim{Name: name}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return jsonClaims(claims, name+".", v)
+	case []interface{}:
+		for i, item := range v {
+			claims = appendJSONValue(claims, name+"."+strconv.Itoa(i), item)
+		}
+		return claims
+	case json.Number:
+		claim.Type = proto.Claim_NUMBER
+		claim.Value = v.String()
+	case bool:
+		claim.Type = proto.Claim_BOOLEAN
+		claim.Value = strconv.FormatBool(v)
+	case string:
+		claim.Type = proto.Claim_STRING
+		claim.Value = v
+	case nil:
+		claim.Type = proto.Claim_STRING
+		claim.Value = "null"
+	}
+	return append(claims, claim)
+}
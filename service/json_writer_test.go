@@ -0,0 +1,64 @@
+package service
+
+import (
+	"github.com/alt4dev/protobuff/proto"
+	"sync"
+	"testing"
+)
+
+func TestJSONWriter(t *testing.T) {
+	defer releaseMode()()
+	Alt4RemoteHelper = remoteHelperMock{}
+	var lock sync.Mutex
+	logs := map[string]*proto.Log{}
+	writeMock = func(msg *proto.Log) {
+		lock.Lock()
+		defer lock.Unlock()
+		logs[msg.Message] = msg
+	}
+
+	writer := NewJSONWriter(JSONWriterOptions{Sync: true})
+	_, _ = writer.Write([]byte(`{"level":"warn","user":{"id":7,"admin":true},"tags":["a","b"],"time":"2020-10-01T10:00:00Z","caller":"/app/main.go:42","message":"slow request"}` + "\n"))
+	_, _ = writer.Write([]byte(`{"level":"error","ts":1601546400.5,"msg":"zap style","error":null}` + "\nnot json at all\n"))
+	_, _ = writer.Write([]byte(`{"level":50,"time":1601546400123456789,"msg":"pino style"}` + "\n"))
+	_, _ = writer.Write([]byte(`{"level":30,"time":1601546400123,"msg":"bunyan style"}` + "\n"))
+
+	structured := logs["slow request"]
+	if structured == nil {
+		t.Fatal("Expected the JSON line to be logged")
+	}
+	if structured.Level != proto.Log_WARNING || structured.File != "/app/main.go" || structured.Line != 42 {
+		t.Errorf("Unexpected entry %v", structured)
+	}
+	if structured.Timestamp != 1601546400000000000 {
+		t.Errorf("Expected the time of the line to be used. Found %d", structured.Timestamp)
+	}
+	expected := map[string]proto.Claim_Type{
+		"user.id":    proto.Claim_NUMBER,
+		"user.admin": proto.Claim_BOOLEAN,
+		"tags.0":     proto.Claim_STRING,
+		"tags.1":     proto.Claim_STRING,
+	}
+	if len(structured.Claims) != len(expected) {
+		t.Errorf("Expected level, message, time and caller to be removed from claims. %v", structured.Claims)
+	}
+	for _, claim := range structured.Claims {
+		if claimType, ok := expected[claim.Name]; !ok || claimType != claim.Type {
+			t.Errorf("Unexpected claim %v", claim)
+		}
+	}
+
+	if zapStyle := logs["zap style"]; zapStyle == nil || zapStyle.Level != proto.Log_ERROR || zapStyle.Timestamp != 1601546400500000000 {
+		t.Errorf("Unexpected entry %v", zapStyle)
+	}
+	// Nanosecond timestamps are kept exactly
+	if pino := logs["pino style"]; pino == nil || pino.Level != proto.Log_ERROR || pino.Timestamp != 1601546400123456789 || len(pino.Claims) != 0 {
+		t.Errorf("Unexpected entry %v", pino)
+	}
+	if bunyan := logs["bunyan style"]; bunyan == nil || bunyan.Level != proto.Log_INFO || bunyan.Timestamp != 1601546400123000000 {
+		t.Errorf("Unexpected entry %v", bunyan)
+	}
+	if plain := logs["not json at all"]; plain == nil || plain.Level != proto.Log_INFO {
+		t.Errorf("Expected plain text to be logged at the default level. Found %v", plain)
+	}
+}
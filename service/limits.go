@@ -0,0 +1,227 @@
+package service
+
+import (
+	"fmt"
+	"github.com/alt4dev/protobuff/proto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TruncationMarker is appended to truncated messages, claim names and claim values.
+const TruncationMarker = "...[truncated]"
+
+// DroppedClaimsClaim is added to entries with more claims than allowed. Its value is the number of claims dropped.
+const DroppedClaimsClaim = "truncated.claims"
+
+// ReservedPrefix is the prefix alt4 uses to search log fields e.g. `alt.level`. Claims using it are renamed with an `_` prefix
+// when Limits.ValidateNames is set.
+const ReservedPrefix = "alt."
+
+// Limits bound the size of entries so that alt4 never rejects an entry for its size.
+// Zero values use the defaults in DefaultLimits, negative values disable a limit.
+type Limits struct {
+	// MaxMessageSize the size of the longest message in bytes.
+	MaxMessageSize int
+	// MaxClaims the number of claims in an entry.
+	MaxClaims int
+	// MaxClaimNameSize the size of the longest claim name in bytes.
+	MaxClaimNameSize int
+	// MaxClaimValueSize the size of the longest claim value in bytes.
+	MaxClaimValueSize int
+	// ValidateNames renames claims with characters other than letters, digits, `_`, `-`, `.`, `@`, `/` and `:` which are replaced by `_`,
+	// and claims starting with the reserved prefix `alt.` which are prefixed with `_`. Claim names are left as is by default.
+	ValidateNames bool
+}
+
+// DefaultLimits the limits used unless changed with SetLimits.
+var DefaultLimits = Limits{
+	MaxMessageSize:    32 * 1024,
+	MaxClaims:         128,
+	MaxClaimNameSize:  256,
+	MaxClaimValueSize: 8 * 1024,
+}
+
+var activeLimits atomic.Value
+
+// LimitsWarningInterval the least time between warnings about entries modified to fit the limits.
+// Entries modified in between are counted and the count is reported with the next warning.
+var LimitsWarningInterval = time.Minute
+
+var limitsWarning struct {
+	sync.Mutex
+	last time.Time
+	// modified the number of entries modified since the last warning
+	modified int
+}
+
+func init() {
+	activeLimits.Store(DefaultLimits)
+}
+
+// SetLimits changes the limits applied to entries. Entries over a limit are truncated and a warning is emitted at most once per LimitsWarningInterval.
+// Empty claim names are replaced by `claim`, see Limits.ValidateNames to validate other names.
+func SetLimits(limits Limits) {
+	limitsWarning.Lock()
+	limitsWarning.last, limitsWarning.modified = time.Time{}, 0
+	limitsWarning.Unlock()
+	if limits.MaxMessageSize == 0 {
+		limits.MaxMessageSize = DefaultLimits.MaxMessageSize
+	}
+	if limits.MaxClaims == 0 {
+		limits.MaxClaims = DefaultLimits.MaxClaims
+	}
+	if limits.MaxClaimNameSize == 0 {
+		limits.MaxClaimNameSize = DefaultLimits.MaxClaimNameSize
+	}
+	if limits.MaxClaimValueSize == 0 {
+		limits.MaxClaimValueSize = DefaultLimits.MaxClaimValueSize
+	}
+	activeLimits.Store(limits)
+}
+
+func getLimits() Limits {
+	return activeLimits.Load().(Limits)
+}
+
+// apply truncates and validates an entry. Claims are copied before they're changed since they may be shared between entries.
+// The changes made are returned.
+func (limits Limits) apply(msg *proto.Log) (changes []string) {
+	if message, ok := truncate(msg.Message, limits.MaxMessageSize); ok {
+		changes = append(changes, fmt.Sprintf("message truncated from %d bytes", len(msg.Message)))
+		msg.Message = message
+	}
+
+	source := msg.Claims
+	// claims is only created once a claim is changed or dropped
+	var claims []*proto.Claim
+	var markers []*proto.Claim
+	dropped := 0
+	if limits.MaxClaims > 0 && len(source) > limits.MaxClaims {
+		// The markers added by SetScrubbing are kept so that redacted entries are still marked as redacted
+		kept := make([]*proto.Claim, 0, len(source))
+		for _, claim := range source {
+			if claim.Name == RedactedClaim || claim.Name == RedactedClaim+".by" {
+				markers = append(markers, claim)
+			} else {
+				kept = append(kept, claim)
+			}
+		}
+		// Room is left for the markers and the number of claims dropped. Markers that don't fit are dropped, `redacted` last.
+		if len(markers) > limits.MaxClaims-1 {
+			sort.SliceStable(markers, func(i, j int) bool {
+				return markers[i].Name == RedactedClaim && markers[j].Name != RedactedClaim
+			})
+			dropped += len(markers) - (limits.MaxClaims - 1)
+			markers = markers[:limits.MaxClaims-1]
+		}
+		room := limits.MaxClaims - len(markers) - 1
+		dropped += len(kept) - room
+		source = kept[:room]
+		claims = make([]*proto.Claim, 0, limits.MaxClaims)
+	}
+	for i, claim := range source {
+		name, claimType, value := claim.Name, claim.Type, claim.Value
+		if valid, reason := validName(name, limits.ValidateNames); reason != "" {
+			changes = append(changes, fmt.Sprintf("claim `%s` renamed to `%s`: %s", name, valid, reason))
+			name = valid
+		}
+		if truncated, ok := truncate(name, limits.MaxClaimNameSize); ok {
+			changes = append(changes, fmt.Sprintf("claim name `%s` truncated", truncated))
+			name = truncated
+		}
+		if truncated, ok := truncate(value, limits.MaxClaimValueSize); ok {
+			changes = append(changes, fmt.Sprintf("claim `%s` truncated from %d bytes", name, len(value)))
+			// Truncated numbers and timestamps are no longer valid
+			value, claimType = truncated, proto.Claim_STRING
+		}
+		changed := name != claim.Name || value != claim.Value
+		if changed && claims == nil {
+			claims = append(make([]*proto.Claim, 0, len(source)), source[:i]...)
+		}
+		if claims == nil {
+			continue
+		}
+		if changed {
+			claims = append(claims, &proto.Claim{Name: name, Type: claimType, Value: value})
+		} else {
+			claims = append(claims, claim)
+		}
+	}
+	if dropped > 0 {
+		changes = append(changes, fmt.Sprintf("%d claims dropped", dropped))
+		claims = append(claims, markers...)
+		claims = append(claims, &proto.Claim{Name: DroppedClaimsClaim, Type: proto.Claim_NUMBER, Value: strconv.Itoa(dropped)})
+	}
+	if claims != nil {
+		msg.Claims = claims
+	}
+	return changes
+}
+
+// warnLimits emits a warning for an entry modified to fit the limits at most once per LimitsWarningInterval.
+// Entries modified in between are only counted so that the output isn't flooded when many entries are over a limit.
+func warnLimits(msg *proto.Log, changes []string) {
+	limitsWarning.Lock()
+	now := time.Now()
+	if !limitsWarning.last.IsZero() && now.Sub(limitsWarning.last) < LimitsWarningInterval {
+		limitsWarning.modified++
+		limitsWarning.Unlock()
+		return
+	}
+	skipped := limitsWarning.modified
+	limitsWarning.last, limitsWarning.modified = now, 0
+	limitsWarning.Unlock()
+
+	warning := fmt.Sprintf("Entry `%s` modified to fit limits: %s", summary(msg.Message), strings.Join(changes, "; "))
+	if skipped > 0 {
+		warning += fmt.Sprintf(". %d more entries were modified since the last warning", skipped)
+	}
+	emitWarning.Println(warning)
+}
+
+// truncate shortens value to size bytes including the marker without splitting characters.
+// The marker is left out if size is smaller than the marker.
+func truncate(value string, size int) (string, bool) {
+	if size < 0 || len(value) <= size {
+		return value, false
+	}
+	marker := TruncationMarker
+	if size < len(marker) {
+		marker = ""
+	}
+	end := size - len(marker)
+	for end > 0 && !utf8.RuneStart(value[end]) {
+		end--
+	}
+	return value[:end] + marker, true
+}
+
+// validName returns a valid claim name and the reason the name was changed if it was.
+// Only empty names are changed unless strict is set.
+func validName(name string, strict bool) (string, string) {
+	if name == "" {
+		return "claim", "empty name"
+	}
+	if !strict {
+		return name, ""
+	}
+	reason := ""
+	valid := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-.@/:", r) {
+			return r
+		}
+		reason = "invalid characters"
+		return '_'
+	}, name)
+	if strings.HasPrefix(strings.ToLower(valid), ReservedPrefix) {
+		valid = "_" + valid
+		reason = "reserved prefix `" + ReservedPrefix + "`"
+	}
+	return valid, reason
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/alt4dev/protobuff/proto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetLimits(t *testing.T) {
+	defer releaseMode()()
+	defer SetLimits(DefaultLimits)
+	var output bytes.Buffer
+	defer SetDebugOutput(options.Writer)
+	SetDebugOutput(&output)
+	Alt4RemoteHelper = remoteHelperMock{}
+	var logged *proto.Log
+	writeMock = func(msg *proto.Log) {
+		logged = msg
+	}
+
+	SetLimits(Limits{MaxMessageSize: 20, MaxClaims: 4, MaxClaimNameSize: 20, MaxClaimValueSize: 16, ValidateNames: true})
+	shared := &proto.Claim{Name: "alt.level", Type: proto.Claim_STRING, Value: "high"}
+	claims := []*proto.Claim{
+		shared,
+		{Name: "", Type: proto.Claim_STRING, Value: "no name"},
+		{Name: "user id", Type: proto.Claim_NUMBER, Value: "12345678901234567890"},
+		{Name: "one", Value: "1"},
+		{Name: "two", Value: "2"},
+	}
+	_, _ = LogCaller("main.go", 1, "main.main", false, "héllo wörld, this is too long", claims, proto.Log_INFO, time.Now()).Result()
+
+	if logged.Message != "héllo"+TruncationMarker || len(logged.Message) > 20 {
+		t.Errorf("Unexpected message %q", logged.Message)
+	}
+	expected := []string{
+		"_alt.level STRING high",
+		"claim STRING no name",
+		"user_id STRING 12" + TruncationMarker,
+		DroppedClaimsClaim + " NUMBER 2",
+	}
+	found := make([]string, 0)
+	for _, claim := range logged.Claims {
+		found = append(found, fmt.Sprintf("%s %s %s", claim.Name, claim.Type, claim.Value))
+	}
+	if strings.Join(found, ",") != strings.Join(expected, ",") {
+		t.Errorf("Unexpected claims %v", found)
+	}
+	if shared.Name != "alt.level" {
+		t.Error("Expected the claims provided to be left unchanged")
+	}
+	for _, warning := range []string{"message truncated from 31 bytes", "claim `alt.level` renamed to `_alt.level`", "2 claims dropped"} {
+		if !strings.Contains(output.String(), warning) {
+			t.Errorf("Expected the warning `%s`. Found %s", warning, output.String())
+		}
+	}
+
+	// Entries within the limits aren't changed
+	output.Reset()
+	claims = []*proto.Claim{{Name: "service", Value: "api"}}
+	_, _ = LogCaller("main.go", 1, "main.main", false, "short", claims, proto.Log_INFO, time.Now()).Result()
+	if logged.Message != "short" || logged.Claims[0] != claims[0] || output.Len() != 0 {
+		t.Errorf("Unexpected entry %v %s", logged, output.String())
+	}
+
+	// Entries modified within LimitsWarningInterval are counted and reported with the next warning
+	output.Reset()
+	_, _ = LogCaller("main.go", 1, "main.main", false, "another message that's too long", nil, proto.Log_INFO, time.Now()).Result()
+	if output.Len() != 0 {
+		t.Errorf("Expected a single warning within the interval. Found %s", output.String())
+	}
+	defer func(interval time.Duration) {
+		LimitsWarningInterval = interval
+	}(LimitsWarningInterval)
+	LimitsWarningInterval = 0
+	_, _ = LogCaller("main.go", 1, "main.main", false, "a third message that's too long", nil, proto.Log_INFO, time.Now()).Result()
+	if !strings.Contains(output.String(), "a thir") || !strings.Contains(output.String(), "1 more entries were modified since the last warning") {
+		t.Errorf("Expected a warning with the number of entries modified since the last one. Found %s", output.String())
+	}
+
+	// Negative limits are disabled
+	SetLimits(Limits{MaxMessageSize: -1})
+	message := strings.Repeat("a", 64*1024)
+	_, _ = LogCaller("main.go", 1, "main.main", false, message, nil, proto.Log_INFO, time.Now()).Result()
+	if logged.Message != message {
+		t.Error("Expected the message not to be truncated")
+	}
+}
+
+func TestLimits_Defaults(t *testing.T) {
+	claims := []*proto.Claim{
+		{Name: "user id", Value: "1"},
+		{Name: "path./api/users", Value: "2"},
+		{Name: "città", Value: "Roma"},
+		{Name: "alt.level", Value: "3"},
+	}
+	msg := &proto.Log{Message: "names", Claims: claims}
+	if changes := DefaultLimits.apply(msg); len(changes) != 0 {
+		t.Errorf("Expected claim names to be left as is by default. Found %v", changes)
+	}
+
+	// Wider characters are allowed when validating names
+	strict := DefaultLimits
+	strict.ValidateNames = true
+	msg = &proto.Log{Message: "names", Claims: claims}
+	strict.apply(msg)
+	names := make([]string, 0)
+	for _, claim := range msg.Claims {
+		names = append(names, claim.Name)
+	}
+	if strings.Join(names, ",") != "user_id,path./api/users,città,_alt.level" {
+		t.Errorf("Unexpected names %v", names)
+	}
+}
+
+func TestLimits_KeepsRedactedMarkers(t *testing.T) {
+	msg := &proto.Log{Claims: []*proto.Claim{
+		{Name: "one", Value: "1"},
+		{Name: "two", Value: "2"},
+		{Name: "three", Value: "3"},
+		{Name: RedactedClaim, Type: proto.Claim_BOOLEAN, Value: "true"},
+		{Name: RedactedClaim + ".by", Value: "email"},
+	}}
+	limits := DefaultLimits
+	limits.MaxClaims = 4
+	limits.apply(msg)
+	found := make([]string, 0)
+	for _, claim := range msg.Claims {
+		found = append(found, claim.Name+"="+claim.Value)
+	}
+	expected := "one=1," + RedactedClaim + "=true," + RedactedClaim + ".by=email," + DroppedClaimsClaim + "=2"
+	if strings.Join(found, ",") != expected {
+		t.Errorf("Expected the redacted markers to be kept. Found %v", found)
+	}
+
+	// Markers that don't fit are dropped so that the limit holds
+	for max, expected := range map[int]string{
+		1: DroppedClaimsClaim + "=5",
+		2: RedactedClaim + "=true," + DroppedClaimsClaim + "=4",
+	} {
+		msg.Claims = []*proto.Claim{
+			{Name: RedactedClaim + ".by", Value: "email"},
+			{Name: "one", Value: "1"},
+			{Name: "two", Value: "2"},
+			{Name: "three", Value: "3"},
+			{Name: RedactedClaim, Type: proto.Claim_BOOLEAN, Value: "true"},
+		}
+		limits.MaxClaims = max
+		limits.apply(msg)
+		found = found[:0]
+		for _, claim := range msg.Claims {
+			found = append(found, claim.Name+"="+claim.Value)
+		}
+		if len(msg.Claims) > max || strings.Join(found, ",") != expected {
+			t.Errorf("Expected %s with MaxClaims %d. Found %v", expected, max, found)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	for _, size := range []int{0, 5, len(TruncationMarker), len(TruncationMarker) + 3} {
+		truncated, ok := truncate(strings.Repeat("é", 20), size)
+		if !ok || len(truncated) > size {
+			t.Errorf("Expected at most %d bytes. Found %q", size, truncated)
+		}
+	}
+}
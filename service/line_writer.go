@@ -0,0 +1,227 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrWriterClosed is returned when writing to a closed LineWriter.
+var ErrWriterClosed = errors.New("alt4: write to a closed writer")
+
+// DefaultContinuationPattern matches lines that continue the previous entry:
+// indented lines and the lines of a Go panic or stack trace e.g. `goroutine 1 [running]:`, `main.main()`.
+// Function lines of a stack trace are package qualified and their arguments are numbers e.g. `main.run(0xc000010000, {0x4b6f20, 0x5})`
+// so lines such as `connected(ok)` aren't merged.
+var DefaultContinuationPattern = regexp.MustCompile(`^(\s|goroutine \d+ \[|created by |\[signal |(panic|[\w.~/-]*[\w~-]\.[\w.*()\[\]~-]+)\(([{}, .?]|0x[0-9a-f]+|\d)*\)$)`)
+
+// LineWriterOptions configure a writer created by NewLineWriter.
+type LineWriterOptions struct {
+	// MergeContinuations merges lines matching ContinuationPattern into the previous entry.
+	// This keeps stack traces and other multi-line messages in a single entry.
+	MergeContinuations bool
+	// ContinuationPattern defaults to DefaultContinuationPattern.
+	ContinuationPattern *regexp.Regexp
+	// FlushTimeout the time to wait for more data before a partial line or pending entry is written. Defaults to 200ms.
+	FlushTimeout time.Duration
+	// MaxEntrySize the size in bytes after which a pending entry is written even if incomplete. Defaults to 64KB.
+	MaxEntrySize int
+	// Writer configures the writer entries are written to when the destination is nil.
+	// Defaults to the flags of a standard library logger i.e. log.LstdFlags so the date and time it adds are removed from messages.
+	// The caller is always read from the logger's output since entries are written from another goroutine.
+	Writer *WriterOptions
+}
+
+// LineWriter buffers writes and passes complete lines to the writer it wraps, one line or entry per Write.
+// Libraries that write partial lines no longer produce fragments, and writes containing many lines produce an entry per line.
+// Close or Flush the writer to write what's pending.
+//
+// Entries are written within the group of the goroutine that wrote their first line, even when they're written later
+// e.g. after the flush timeout. Closing that group or waiting for its WaitGroup waits for its pending entries to be written.
+type LineWriter struct {
+	dst     io.Writer
+	opts    LineWriterOptions
+	lock    sync.Mutex
+	partial []byte
+	entry   strings.Builder
+	blanks  int
+	timer   *time.Timer
+	closed  bool
+	err     error
+	// partialOwner and entryOwner the goroutines that started the partial line and the pending entry
+	partialOwner *routine
+	entryOwner   *routine
+}
+
+// NewLineWriter creates a writer that writes complete lines to dst.
+// If dst is nil, entries are written to alt4 by NewWriter configured with opts.Writer.
+// Example: log.SetOutput(service.NewLineWriter(nil, service.LineWriterOptions{MergeContinuations: true}))
+func NewLineWriter(dst io.Writer, opts LineWriterOptions) *LineWriter {
+	if dst == nil {
+		writerOpts := WriterOptions{Flags: log.LstdFlags}
+		if opts.Writer != nil {
+			writerOpts = *opts.Writer
+		}
+		// Entries are written after a timeout from another goroutine so the caller can't be read from the stack
+		writerOpts.CallDepth = -1
+		dst = NewWriter(writerOpts)
+	}
+	if opts.ContinuationPattern == nil {
+		opts.ContinuationPattern = DefaultContinuationPattern
+	}
+	if opts.FlushTimeout <= 0 {
+		opts.FlushTimeout = 200 * time.Millisecond
+	}
+	if opts.MaxEntrySize <= 0 {
+		opts.MaxEntrySize = 64 * 1024
+	}
+	return &LineWriter{dst: dst, opts: opts}
+}
+
+// Write buffers p and writes the complete lines it contains.
+// Errors from the wrapped writer are returned by the next call to Write, Flush or Close.
+func (writer *LineWriter) Write(p []byte) (n int, err error) {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+	if writer.closed {
+		return 0, ErrWriterClosed
+	}
+
+	current := currentRoutine()
+	data := append(writer.partial, p...)
+	for {
+		index := bytes.IndexByte(data, '\n')
+		if index < 0 {
+			break
+		}
+		owner := current
+		if writer.partialOwner != nil {
+			owner = writer.partialOwner
+		}
+		writer.addLine(string(bytes.TrimSuffix(data[:index], []byte("\r"))), owner)
+		writer.releasePartial()
+		data = data[index+1:]
+	}
+	writer.partial = append([]byte{}, data...)
+	if len(writer.partial) > 0 && writer.partialOwner == nil {
+		current.wg.Add(1)
+		writer.partialOwner = current
+	}
+	if len(writer.partial) >= writer.opts.MaxEntrySize {
+		writer.flushPartial()
+	}
+
+	if len(writer.partial) > 0 || writer.entry.Len() > 0 {
+		if writer.timer == nil {
+			writer.timer = time.AfterFunc(writer.opts.FlushTimeout, func() {
+				_ = writer.Flush()
+			})
+		} else {
+			writer.timer.Reset(writer.opts.FlushTimeout)
+		}
+	}
+	return len(p), writer.takeError()
+}
+
+// Flush writes the pending entry and partial line.
+func (writer *LineWriter) Flush() error {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+	writer.flush()
+	return writer.takeError()
+}
+
+// Close flushes the writer. Writes after Close fail with ErrWriterClosed.
+func (writer *LineWriter) Close() error {
+	writer.lock.Lock()
+	defer writer.lock.Unlock()
+	if writer.closed {
+		return nil
+	}
+	writer.flush()
+	writer.closed = true
+	if writer.timer != nil {
+		writer.timer.Stop()
+	}
+	return writer.takeError()
+}
+
+func (writer *LineWriter) flush() {
+	writer.flushPartial()
+	writer.writeEntry()
+}
+
+func (writer *LineWriter) flushPartial() {
+	if len(writer.partial) > 0 {
+		writer.addLine(string(writer.partial), writer.partialOwner)
+		writer.partial = nil
+	}
+	writer.releasePartial()
+}
+
+func (writer *LineWriter) releasePartial() {
+	if writer.partialOwner != nil {
+		writer.partialOwner.wg.Done()
+		writer.partialOwner = nil
+	}
+}
+
+// addLine adds a line started by owner.
+func (writer *LineWriter) addLine(line string, owner *routine) {
+	if !writer.opts.MergeContinuations {
+		if strings.TrimSpace(line) != "" {
+			writer.write(line, owner)
+		}
+		return
+	}
+	if strings.TrimSpace(line) == "" {
+		// Blank lines are kept only if the entry continues after them e.g. between a panic and its stack trace
+		if writer.entry.Len() > 0 {
+			writer.blanks++
+		}
+		return
+	}
+	if writer.entry.Len() > 0 && writer.opts.ContinuationPattern.MatchString(line) {
+		writer.entry.WriteString(strings.Repeat("\n", writer.blanks+1))
+		writer.entry.WriteString(line)
+		writer.blanks = 0
+	} else {
+		writer.writeEntry()
+		writer.entry.WriteString(line)
+		owner.wg.Add(1)
+		writer.entryOwner = owner
+	}
+	if writer.entry.Len() >= writer.opts.MaxEntrySize {
+		writer.writeEntry()
+	}
+}
+
+func (writer *LineWriter) writeEntry() {
+	if writer.entry.Len() > 0 {
+		writer.write(writer.entry.String(), writer.entryOwner)
+		writer.entry.Reset()
+		writer.entryOwner.wg.Done()
+		writer.entryOwner = nil
+	}
+	writer.blanks = 0
+}
+
+// write writes entry within the group of owner.
+func (writer *LineWriter) write(entry string, owner *routine) {
+	owner.do(func() {
+		if _, err := writer.dst.Write([]byte(entry)); err != nil && writer.err == nil {
+			writer.err = err
+		}
+	})
+}
+
+func (writer *LineWriter) takeError() error {
+	err := writer.err
+	writer.err = nil
+	return err
+}
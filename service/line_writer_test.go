@@ -0,0 +1,139 @@
+package service
+
+import (
+	"github.com/alt4dev/protobuff/proto"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+type entryRecorder struct {
+	lock    sync.Mutex
+	entries []string
+}
+
+func (recorder *entryRecorder) Write(p []byte) (int, error) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	recorder.entries = append(recorder.entries, string(p))
+	return len(p), nil
+}
+
+func (recorder *entryRecorder) written() []string {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+	return append([]string{}, recorder.entries...)
+}
+
+func TestLineWriter(t *testing.T) {
+	recorder := &entryRecorder{}
+	writer := NewLineWriter(recorder, LineWriterOptions{FlushTimeout: time.Hour})
+	_, _ = writer.Write([]byte("first pa"))
+	_, _ = writer.Write([]byte("rt\nsecond\n\nthird"))
+	if entries := recorder.written(); len(entries) != 2 || entries[0] != "first part" || entries[1] != "second" {
+		t.Errorf("Expected complete lines to be written. Found %q", entries)
+	}
+	_ = writer.Close()
+	if entries := recorder.written(); len(entries) != 3 || entries[2] != "third" {
+		t.Errorf("Expected Close to write the partial line. Found %q", entries)
+	}
+	if _, err := writer.Write([]byte("late\n")); err != ErrWriterClosed {
+		t.Error("Expected writes after Close to fail")
+	}
+}
+
+func TestLineWriterMergeContinuations(t *testing.T) {
+	recorder := &entryRecorder{}
+	writer := NewLineWriter(recorder, LineWriterOptions{MergeContinuations: true, FlushTimeout: 20 * time.Millisecond})
+	trace := "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:5 +0x27\n"
+	_, _ = writer.Write([]byte("starting\n" + trace))
+	_, _ = writer.Write([]byte("after the panic\n"))
+	if entries := recorder.written(); len(entries) != 2 || entries[0] != "starting" || entries[1] != "panic: boom\n\ngoroutine 1 [running]:\nmain.main()\n\t/app/main.go:5 +0x27" {
+		t.Errorf("Expected the stack trace to be merged into a single entry. Found %q", entries)
+	}
+
+	// The pending entry is written after the timeout
+	time.Sleep(100 * time.Millisecond)
+	if entries := recorder.written(); len(entries) != 3 || entries[2] != "after the panic" {
+		t.Errorf("Expected the pending entry to be written after the timeout. Found %q", entries)
+	}
+}
+
+func TestLineWriterStdLogger(t *testing.T) {
+	defer releaseMode()()
+	Alt4RemoteHelper = remoteHelperMock{}
+	var lock sync.Mutex
+	var messages []string
+	writeMock = func(msg *proto.Log) {
+		lock.Lock()
+		defer lock.Unlock()
+		messages = append(messages, msg.Message)
+	}
+	writer := NewLineWriter(nil, LineWriterOptions{FlushTimeout: time.Hour})
+	logger := log.New(writer, "", log.LstdFlags)
+	logger.Println("[WARN] disk at 91%")
+	_ = writer.Close()
+	WaitGroup().Wait()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(messages) != 1 || messages[0] != "disk at 91%" {
+		t.Errorf("Expected the date and time added by the logger to be removed. Found %q", messages)
+	}
+}
+
+func TestLineWriterTimeoutGroup(t *testing.T) {
+	defer releaseMode()()
+	Alt4RemoteHelper = remoteHelperMock{}
+	var lock sync.Mutex
+	threads := map[string]string{}
+	writeMock = func(msg *proto.Log) {
+		lock.Lock()
+		defer lock.Unlock()
+		threads[msg.Message] = msg.Thread
+	}
+	writer := NewLineWriter(nil, LineWriterOptions{MergeContinuations: true, FlushTimeout: 20 * time.Millisecond, Writer: &WriterOptions{}})
+	defer writer.Close()
+	JoinGroup(0, "line-writer-thread", "Writing lines", nil, proto.Log_NONE, LogTime())
+	_, _ = writer.Write([]byte("last line of a burst\n"))
+	// Closing the group waits for the entry written after the timeout
+	CloseGroup()
+
+	lock.Lock()
+	defer lock.Unlock()
+	if thread, ok := threads["last line of a burst"]; !ok || thread != "line-writer-thread" {
+		t.Errorf("Expected the entry to be written within the writer's group. Found %q %v", thread, ok)
+	}
+}
+
+func TestDefaultContinuationPattern(t *testing.T) {
+	continuations := []string{
+		"goroutine 1 [running]:",
+		"main.main()",
+		"\t/app/main.go:5 +0x27",
+		"main.main.func1()",
+		"panic({0x4b6f20?, 0xc000010250?})",
+		"github.com/alt4dev/go/service.(*LineWriter).Write(0xc000112000, {0xc000130000, 0x5, 0x5})",
+		"net/http.(*conn).serve(0xc0000b4000, {0x6f0e38, 0xc000096000})",
+		"main.Map[...](...)",
+		"created by main.start in goroutine 1",
+	}
+	for _, line := range continuations {
+		if !DefaultContinuationPattern.MatchString(line) {
+			t.Errorf("Expected `%s` to continue the previous entry", line)
+		}
+	}
+	lines := []string{
+		"connected(ok)",
+		"retrying(3 attempts left)",
+		"fmt.Println(user)",
+		"done (took 3s)",
+		"user.save() failed",
+	}
+	for _, line := range lines {
+		if DefaultContinuationPattern.MatchString(line) {
+			t.Errorf("Expected `%s` to start a new entry", line)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"github.com/alt4dev/protobuff/proto"
 	"runtime"
@@ -12,14 +13,48 @@ import (
 // This function should not be called directly and should instead be used from helper functions under the `log` package.
 func Log(calldepth int, asGroup bool, message string, claims []*proto.Claim, level proto.Log_Level, logTime time.Time) *LogResult {
 	if asGroup {
-		initGroup()
+		initGroup("")
 	}
+	return writeLog(calldepth+1, asGroup, message, claims, level, logTime)
+}
+
+// JoinGroup Creates a group entry like Log but the group continues the thread `threadId` instead of starting a new one.
+// This is used to place logs from different services or processes in the same group e.g. by propagating the thread id in request headers.
+// This function should not be called directly and should instead be used from helper functions under the `log` package.
+func JoinGroup(calldepth int, threadId string, message string, claims []*proto.Claim, level proto.Log_Level, logTime time.Time) *LogResult {
+	initGroup(threadId)
+	return writeLog(calldepth+1, true, message, claims, level, logTime)
+}
+
+// LogCaller Creates a log entry like Log but the file, line and function are provided instead of being read from the call stack.
+// This is used by integrations that already know where a log came from e.g. from the PC of a record or a parsed log line.
+func LogCaller(file string, line int, function string, asGroup bool, message string, claims []*proto.Claim, level proto.Log_Level, logTime time.Time) *LogResult {
+	if asGroup {
+		initGroup("")
+	}
+	return writeEntry(nil, nil, file, line, function, asGroup, message, claims, level, logTime)
+}
+
+// LogCallerContext Creates a log entry like LogCaller for loggers that have a context and claim providers of their own.
+// The providers are called after the ones added with AddClaimProvider.
+func LogCallerContext(ctx context.Context, providers []ClaimProvider, file string, line int, function string, asGroup bool, message string, claims []*proto.Claim, level proto.Log_Level, logTime time.Time) *LogResult {
+	if asGroup {
+		initGroup("")
+	}
+	return writeEntry(ctx, providers, file, line, function, asGroup, message, claims, level, logTime)
+}
+
+func writeLog(calldepth int, asGroup bool, message string, claims []*proto.Claim, level proto.Log_Level, logTime time.Time) *LogResult {
 	// Get the parent file and function of the caller
 	pc, file, line, _ := runtime.Caller(calldepth)
 	function := runtime.FuncForPC(pc).Name()
-	msg := proto.Log{
-		Source:    options.Source,
-		Thread:    getThreadId(),
+	return writeEntry(nil, nil, file, line, function, asGroup, message, claims, level, logTime)
+}
+
+func writeEntry(ctx context.Context, loggerProviders []ClaimProvider, file string, line int, function string, asGroup bool, message string, claims []*proto.Claim, level proto.Log_Level, logTime time.Time) *LogResult {
+	claims = provideClaims(ctx, loggerProviders, claims, level, message, file, line, function)
+	checkClaims(claims)
+	return LogEntry(&proto.Log{
 		Message:   message,
 		Claims:    claims,
 		File:      file,
@@ -28,23 +63,43 @@ func Log(calldepth int, asGroup bool, message string, claims []*proto.Claim, lev
 		Level:     level,
 		Timestamp: uint64(logTime.UnixNano()),
 		Group:     asGroup,
+	})
+}
+
+// LogEntry Writes an entry that's already been built e.g. by integrations that receive logs from other processes.
+// The source and thread are set if they're empty. The thread is that of the group open in the calling goroutine.
+// Entries are redacted as configured with SetScrubbing and fitted to the limits set with SetLimits before they're written or emitted.
+func LogEntry(msg *proto.Log) *LogResult {
+	if s := getScrubber(); s != nil {
+		s.scrub(msg)
+	}
+	if changes := getLimits().apply(msg); len(changes) > 0 {
+		warnLimits(msg, changes)
+	}
+	if msg.Source == "" {
+		msg.Source = options.Source
+	}
+	if msg.Thread == "" {
+		msg.Thread = getThreadId()
 	}
 	result := LogResult{
 		wg: WaitGroup(),
 	}
 	if options.Mode == ModeDebug || options.Mode == ModeTesting {
 		// Write to stderr if conditions are met.
-		emitLog(&msg)
+		emitLog(msg)
 	}
 	if options.Mode != ModeTesting && options.Mode != ModeSilent {
 		WaitGroup().Add(1)
-		go writerHelper(&msg, &result)
+		result.done = make(chan struct{})
+		go writerHelper(msg, &result)
 	}
 	return &result
 }
 
 func writerHelper(msg *proto.Log, result *LogResult) {
 	defer result.wg.Done()
+	defer close(result.done)
 	Alt4RemoteHelper.WriteLog(msg, result)
 }
 
@@ -58,3 +113,8 @@ func emitLog(msg *proto.Log) {
 	emit.Println(strings.Join(lines, "\n"))
 }
 
+// summary shortens a message for warnings
+func summary(message string) string {
+	short, _ := truncate(message, 64)
+	return short
+}
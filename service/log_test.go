@@ -0,0 +1,53 @@
+package service
+
+import (
+	"github.com/alt4dev/protobuff/proto"
+	"testing"
+)
+
+// releaseMode makes sure writes reach the remote helper. Returns a function that restores the previous mode.
+func releaseMode() func() {
+	mode := options.Mode
+	SetMode(ModeRelease)
+	return func() {
+		SetMode(mode)
+	}
+}
+
+func TestLogCaller(t *testing.T) {
+	defer releaseMode()()
+	Alt4RemoteHelper = remoteHelperMock{}
+	writeMock = func(msg *proto.Log) {
+		if msg.File != "/src/app/main.go" || msg.Line != 42 || msg.Function != "main.main" {
+			t.Errorf("Expected the caller provided to be used. %s:%d %s", msg.File, msg.Line, msg.Function)
+		}
+		if msg.Message != "A message from elsewhere" || msg.Level != proto.Log_WARNING {
+			t.Error("Unexpected message logged")
+			t.Error(msg)
+		}
+	}
+	_, _ = LogCaller("/src/app/main.go", 42, "main.main", false, "A message from elsewhere", nil, proto.Log_WARNING, LogTime()).Result()
+}
+
+func TestLogResult_Done(t *testing.T) {
+	defer releaseMode()()
+	release := make(chan struct{})
+	Alt4RemoteHelper = remoteHelperMock{}
+	writeMock = func(msg *proto.Log) {
+		<-release
+	}
+	result := Log(1, false, "A slow write", nil, proto.Log_INFO, LogTime())
+	select {
+	case <-result.Done():
+		t.Error("Done should block until the entry is written")
+	default:
+	}
+	close(release)
+	<-result.Done()
+	if result.R == nil {
+		t.Error("Expected the result to be available once done")
+	}
+
+	// Results without a pending write are done
+	<-(&LogResult{}).Done()
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"github.com/alt4dev/protobuff/proto"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+)
+
+// Release the release version of the process added to its metadata as the claim `release`.
+// Set it when building e.g. go build -ldflags "-X github.com/alt4dev/go/service.Release=v1.2.3"
+var Release = ""
+
+// StartupMessage the message of the entry written by LogStartup.
+const StartupMessage = "Process started"
+
+var metadataOnce sync.Once
+var processMetadata []*proto.Claim
+
+var startupOnce sync.Once
+var startupResult *LogResult
+
+// Metadata returns claims that identify the process so that entries can be correlated to a host and a deploy:
+// `host.name`, `host.os`, `host.arch`, `process.pid`, `go.version`, `release` if set and from the build info
+// `build.module`, `build.version` and, for binaries built with go 1.18 and later in a repository, `build.revision`, `build.time` and `build.modified`.
+// The claims are read once and shared so they shouldn't be modified.
+func Metadata() []*proto.Claim {
+	metadataOnce.Do(func() {
+		processMetadata = readMetadata()
+	})
+	return processMetadata
+}
+
+// EnrichEntries adds the metadata of the process to every entry logged from it and returns a function that stops it.
+// See Metadata and LogStartup to add the metadata once instead.
+func EnrichEntries() (remove func()) {
+	return AddClaimProvider(func(entry Entry) []*proto.Claim {
+		return Metadata()
+	})
+}
+
+// LogStartup writes an entry with the metadata of the process. The entry is written once per process,
+// later calls return the result of the first entry. Example: call `alt4Service.LogStartup()` at the start of main.
+func LogStartup() *LogResult {
+	pc, file, line, _ := runtime.Caller(1)
+	function := runtime.FuncForPC(pc).Name()
+	startupOnce.Do(func() {
+		startupResult = LogCaller(file, line, function, false, StartupMessage, Metadata(), proto.Log_INFO, LogTime())
+	})
+	return startupResult
+}
+
+func readMetadata() []*proto.Claim {
+	var claims []*proto.Claim
+	add := func(name string, claimType proto.Claim_Type, value string) {
+		if value != "" {
+			claims = append(claims, &proto.Claim{Name: name, Type: claimType, Value: value})
+		}
+	}
+	hostname, _ := os.Hostname()
+	add("host.name", proto.Claim_STRING, hostname)
+	add("host.os", proto.Claim_STRING, runtime.GOOS)
+	add("host.arch", proto.Claim_STRING, runtime.GOARCH)
+	add("process.pid", proto.Claim_NUMBER, strconv.Itoa(os.Getpid()))
+	add("go.version", proto.Claim_STRING, runtime.Version())
+	add("release", proto.Claim_STRING, Release)
+	if info, ok := debug.ReadBuildInfo(); ok {
+		add("build.module", proto.Claim_STRING, info.Main.Path)
+		add("build.version", proto.Claim_STRING, info.Main.Version)
+		for _, setting := range vcsSettings(info) {
+			add(setting.Name, setting.Type, setting.Value)
+		}
+	}
+	return claims
+}
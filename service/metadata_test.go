@@ -0,0 +1,84 @@
+package service
+
+import (
+	"github.com/alt4dev/protobuff/proto"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func claimsByName(claims []*proto.Claim) map[string]*proto.Claim {
+	byName := map[string]*proto.Claim{}
+	for _, claim := range claims {
+		byName[claim.Name] = claim
+	}
+	return byName
+}
+
+func TestMetadata(t *testing.T) {
+	Release = "v1.2.3"
+	metadataOnce = sync.Once{}
+	defer func() {
+		Release = ""
+		metadataOnce = sync.Once{}
+	}()
+
+	claims := claimsByName(Metadata())
+	expected := map[string]string{
+		"host.os":     runtime.GOOS,
+		"host.arch":   runtime.GOARCH,
+		"process.pid": strconv.Itoa(os.Getpid()),
+		"go.version":  runtime.Version(),
+		"release":     "v1.2.3",
+	}
+	for name, value := range expected {
+		if claims[name] == nil || claims[name].Value != value {
+			t.Errorf("Expected claim `%s` to be `%s`, got %v", name, value, claims[name])
+		}
+	}
+	if claims["process.pid"] != nil && claims["process.pid"].Type != proto.Claim_NUMBER {
+		t.Error("Expected the pid to be a number")
+	}
+}
+
+func TestEnrichEntries(t *testing.T) {
+	defer releaseMode()()
+	Alt4RemoteHelper = remoteHelperMock{}
+	var logged *proto.Log
+	writeMock = func(msg *proto.Log) {
+		logged = msg
+	}
+	remove := EnrichEntries()
+	_, _ = Log(1, false, "A message", nil, proto.Log_INFO, LogTime()).Result()
+	remove()
+	if claimsByName(logged.Claims)["process.pid"] == nil {
+		t.Errorf("Expected the metadata to be added to the entry, got %v", logged.Claims)
+	}
+	_, _ = Log(1, false, "A message", nil, proto.Log_INFO, LogTime()).Result()
+	if len(logged.Claims) != 0 {
+		t.Errorf("Expected no metadata once removed, got %v", logged.Claims)
+	}
+}
+
+func TestLogStartup(t *testing.T) {
+	defer releaseMode()()
+	Alt4RemoteHelper = remoteHelperMock{}
+	var entries []*proto.Log
+	writeMock = func(msg *proto.Log) {
+		entries = append(entries, msg)
+	}
+	line := whereAmI() + 1
+	first := LogStartup()
+	_, _ = first.Result()
+	if LogStartup() != first {
+		t.Error("Expected later calls to return the result of the first entry")
+	}
+	if len(entries) != 1 || entries[0].Message != StartupMessage || entries[0].Line != uint32(line) {
+		t.Fatalf("Expected a single startup entry from the caller, got %v", entries)
+	}
+	if claimsByName(entries[0].Claims)["go.version"] == nil {
+		t.Errorf("Expected the startup entry to have the metadata, got %v", entries[0].Claims)
+	}
+}
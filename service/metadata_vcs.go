@@ -0,0 +1,29 @@
+//go:build go1.18
+// +build go1.18
+
+package service
+
+import (
+	"github.com/alt4dev/protobuff/proto"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// vcsSettings returns the version control information stamped in the binary by go build.
+func vcsSettings(info *debug.BuildInfo) []*proto.Claim {
+	var claims []*proto.Claim
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			claims = append(claims, &proto.Claim{Name: "build.revision", Type: proto.Claim_STRING, Value: setting.Value})
+		case "vcs.time":
+			if t, err := time.Parse(time.RFC3339, setting.Value); err == nil {
+				claims = append(claims, &proto.Claim{Name: "build.time", Type: proto.Claim_TIMESTAMP, Value: strconv.FormatInt(t.UnixNano(), 10)})
+			}
+		case "vcs.modified":
+			claims = append(claims, &proto.Claim{Name: "build.modified", Type: proto.Claim_BOOLEAN, Value: setting.Value})
+		}
+	}
+	return claims
+}
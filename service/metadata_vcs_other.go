@@ -0,0 +1,14 @@
+//go:build !go1.18
+// +build !go1.18
+
+package service
+
+import (
+	"github.com/alt4dev/protobuff/proto"
+	"runtime/debug"
+)
+
+// vcsSettings returns nothing since go versions before 1.18 don't stamp version control information in binaries.
+func vcsSettings(info *debug.BuildInfo) []*proto.Claim {
+	return nil
+}
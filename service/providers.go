@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"github.com/alt4dev/protobuff/proto"
+	"sync"
+	"sync/atomic"
+)
+
+// Entry describes the entry that claim providers add claims to.
+type Entry struct {
+	// Context the context the entry was logged with. It's the context set with SetContext if the logger doesn't have one
+	// and context.Background() if neither is set.
+	Context  context.Context
+	Level    proto.Log_Level
+	Message  string
+	File     string
+	Line     int
+	Function string
+}
+
+// ClaimProvider returns claims to add to an entry e.g. the current tenant from the context or the memory in use.
+// Providers are called for every entry so they should be fast.
+type ClaimProvider func(entry Entry) []*proto.Claim
+
+type providerRef struct {
+	provider ClaimProvider
+}
+
+var providersLock sync.Mutex
+var providers atomic.Value
+var contexts sync.Map
+var claimsCheck atomic.Value
+
+func init() {
+	providers.Store([]*providerRef(nil))
+}
+
+// AddClaimProvider adds a provider that's called for every entry logged from this process and returns a function that removes it.
+// Providers aren't called for entries received from other processes e.g. by alt4syslog.
+// A provider that panics is skipped for that entry and the panic is emitted as a warning.
+func AddClaimProvider(provider ClaimProvider) (remove func()) {
+	ref := &providerRef{provider: provider}
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	current := providers.Load().([]*providerRef)
+	updated := make([]*providerRef, len(current), len(current)+1)
+	copy(updated, current)
+	providers.Store(append(updated, ref))
+
+	return func() {
+		providersLock.Lock()
+		defer providersLock.Unlock()
+		current := providers.Load().([]*providerRef)
+		updated := make([]*providerRef, 0, len(current))
+		for _, r := range current {
+			if r != ref {
+				updated = append(updated, r)
+			}
+		}
+		providers.Store(updated)
+	}
+}
+
+// SetContext sets the context passed to claim providers for entries logged from the calling goroutine.
+// Call restore to remove it, usually with defer. Example: defer service.SetContext(r.Context())()
+func SetContext(ctx context.Context) (restore func()) {
+	routineId := getRoutineId()
+	previous, hadPrevious := contexts.Load(routineId)
+	contexts.Store(routineId, ctx)
+	return func() {
+		if hadPrevious {
+			contexts.Store(routineId, previous)
+		} else {
+			contexts.Delete(routineId)
+		}
+	}
+}
+
+// SetClaimsCheck sets a function called with the claims of every entry logged from this process once claim providers are called.
+// It's used by the log package to check the claims required by claim schemas. Entries received from other processes aren't checked.
+func SetClaimsCheck(check func(claims []*proto.Claim)) {
+	claimsCheck.Store(check)
+}
+
+func checkClaims(claims []*proto.Claim) {
+	if check, _ := claimsCheck.Load().(func(claims []*proto.Claim)); check != nil {
+		check(claims)
+	}
+}
+
+// provideClaims returns claims with the claims of the global providers and then loggerProviders appended.
+// The claims slice of the caller isn't modified.
+func provideClaims(ctx context.Context, loggerProviders []ClaimProvider, claims []*proto.Claim, level proto.Log_Level, message string, file string, line int, function string) []*proto.Claim {
+	global := providers.Load().([]*providerRef)
+	if len(global) == 0 && len(loggerProviders) == 0 {
+		return claims
+	}
+	if ctx == nil {
+		if val, ok := contexts.Load(getRoutineId()); ok {
+			ctx = val.(context.Context)
+		} else {
+			ctx = context.Background()
+		}
+	}
+	entry := Entry{Context: ctx, Level: level, Message: message, File: file, Line: line, Function: function}
+	// Limit the capacity so that appending never writes to the backing array of the caller
+	claims = claims[:len(claims):len(claims)]
+	for _, ref := range global {
+		claims = append(claims, callProvider(ref.provider, entry)...)
+	}
+	for _, provider := range loggerProviders {
+		claims = append(claims, callProvider(provider, entry)...)
+	}
+	return claims
+}
+
+func callProvider(provider ClaimProvider, entry Entry) (claims []*proto.Claim) {
+	defer func() {
+		if r := recover(); r != nil {
+			emitWarning.Printf("Claim provider panicked, its claims were skipped: %v", r)
+			claims = nil
+		}
+	}()
+	return provider(entry)
+}
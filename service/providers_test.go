@@ -0,0 +1,97 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"github.com/alt4dev/protobuff/proto"
+	"strings"
+	"testing"
+)
+
+type tenantKey struct{}
+
+func tenantProvider(entry Entry) []*proto.Claim {
+	tenant, ok := entry.Context.Value(tenantKey{}).(string)
+	if !ok {
+		return nil
+	}
+	return []*proto.Claim{{Name: "tenant", Type: proto.Claim_STRING, Value: tenant}}
+}
+
+func TestAddClaimProvider(t *testing.T) {
+	defer releaseMode()()
+	Alt4RemoteHelper = remoteHelperMock{}
+	var entries []Entry
+	remove := AddClaimProvider(func(entry Entry) []*proto.Claim {
+		entries = append(entries, entry)
+		return []*proto.Claim{{Name: "level", Type: proto.Claim_STRING, Value: entry.Level.String()}}
+	})
+	defer remove()
+	defer AddClaimProvider(tenantProvider)()
+	defer SetContext(context.WithValue(context.Background(), tenantKey{}, "acme"))()
+
+	var logged *proto.Log
+	writeMock = func(msg *proto.Log) {
+		logged = msg
+	}
+	claims := make([]*proto.Claim, 1, 4)
+	claims[0] = &proto.Claim{Name: "user", Type: proto.Claim_STRING, Value: "jane"}
+	_, _ = LogCaller("main.go", 7, "main.main", false, "A message", claims, proto.Log_WARNING, LogTime()).Result()
+
+	if len(entries) != 1 || entries[0].File != "main.go" || entries[0].Line != 7 || entries[0].Function != "main.main" || entries[0].Message != "A message" {
+		t.Fatalf("Expected the provider to be called with the entry, got %v", entries)
+	}
+	if len(logged.Claims) != 3 || logged.Claims[1].Value != "WARNING" || logged.Claims[2].Value != "acme" {
+		t.Errorf("Expected the provided claims to be added, got %v", logged.Claims)
+	}
+	if claims[:2][1] != nil {
+		t.Error("Expected the claims of the caller to be left as is")
+	}
+
+	// Removed providers aren't called
+	remove()
+	_, _ = LogCaller("main.go", 7, "main.main", false, "A message", nil, proto.Log_INFO, LogTime()).Result()
+	if len(entries) != 1 || len(logged.Claims) != 1 {
+		t.Errorf("Expected the removed provider not to be called, got %v", logged.Claims)
+	}
+}
+
+func TestLogCallerContext(t *testing.T) {
+	defer releaseMode()()
+	Alt4RemoteHelper = remoteHelperMock{}
+	var logged *proto.Log
+	writeMock = func(msg *proto.Log) {
+		logged = msg
+	}
+	ctx := context.WithValue(context.Background(), tenantKey{}, "globex")
+	_, _ = LogCallerContext(ctx, []ClaimProvider{tenantProvider}, "main.go", 7, "main.main", false, "A message", nil, proto.Log_INFO, LogTime()).Result()
+	if len(logged.Claims) != 1 || logged.Claims[0].Value != "globex" {
+		t.Errorf("Expected the provider of the logger to use its context, got %v", logged.Claims)
+	}
+}
+
+func TestClaimProvider_Panic(t *testing.T) {
+	defer releaseMode()()
+	Alt4RemoteHelper = remoteHelperMock{}
+	output := &bytes.Buffer{}
+	defer SetDebugOutput(options.Writer)
+	SetDebugOutput(output)
+	defer AddClaimProvider(func(entry Entry) []*proto.Claim {
+		panic("provider failed")
+	})()
+	defer AddClaimProvider(func(entry Entry) []*proto.Claim {
+		return []*proto.Claim{{Name: "healthy", Type: proto.Claim_BOOLEAN, Value: "true"}}
+	})()
+
+	var logged *proto.Log
+	writeMock = func(msg *proto.Log) {
+		logged = msg
+	}
+	_, _ = Log(1, false, "A message", nil, proto.Log_INFO, LogTime()).Result()
+	if logged == nil || len(logged.Claims) != 1 || logged.Claims[0].Name != "healthy" {
+		t.Errorf("Expected the entry to be logged with the claims of the other providers, got %v", logged)
+	}
+	if !strings.Contains(output.String(), "provider failed") {
+		t.Errorf("Expected the panic to be emitted, got %s", output.String())
+	}
+}
@@ -10,6 +10,7 @@ import (
 type LogResult struct {
 	R   *proto.Result
 	wg *sync.WaitGroup
+	done chan struct{}
 	Err error
 }
 
@@ -19,6 +20,21 @@ func (result *LogResult) Result() (*proto.Result, error) {
 	return result.R, result.Err
 }
 
+var closedChannel = make(chan struct{})
+
+func init() {
+	close(closedChannel)
+}
+
+// Done Returns a channel that's closed once this entry has been written.
+// Unlike Result, it doesn't wait for the other writes started by the goroutine that created the entry.
+func (result *LogResult) Done() <-chan struct{} {
+	if result.done == nil {
+		return closedChannel
+	}
+	return result.done
+}
+
 // RemoteWriter an interface for functions called when writing to alt4.
 // You can implement this function to mock writes to alt4 for better testing of your system.
 type RemoteHelper interface {
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/alt4dev/protobuff/proto"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultMask replaces redacted values.
+const DefaultMask = "REDACTED"
+
+// RedactedClaim is added to entries that were redacted. `redacted.by` lists the claims and detectors that caused it.
+const RedactedClaim = "redacted"
+
+// Detector finds secrets or personal data in messages and claim values.
+type Detector struct {
+	// Name identifies the detector in the claim `redacted.by`.
+	Name string
+	// Pattern matches what's redacted. If the pattern has a group named `secret` only the group is redacted e.g. the value of `password=...`.
+	Pattern *regexp.Regexp
+	// Valid is an optional check on each match to avoid false positives e.g. the checksum of card numbers.
+	Valid func(match string) bool
+}
+
+// Built-in detectors
+var (
+	DetectEmail      = Detector{Name: "email", Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)}
+	DetectCardNumber = Detector{Name: "card_number", Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), Valid: luhn}
+	DetectBearer     = Detector{Name: "bearer_token", Pattern: regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9\-._~+/]+=*)`)}
+	DetectJWT        = Detector{Name: "jwt", Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)}
+	DetectAWSKey     = Detector{Name: "aws_access_key", Pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)}
+	DetectPrivateKey = Detector{Name: "private_key", Pattern: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)}
+	DetectAssignment = Detector{Name: "secret_assignment", Pattern: regexp.MustCompile(`(?i)\b(?:password|passwd|pwd|secret|token|api[_-]?key)\s*[=:]\s*(?P<secret>[^\s&,;"']+)`)}
+)
+
+// DefaultDetectors the built-in detectors.
+var DefaultDetectors = []Detector{DetectPrivateKey, DetectJWT, DetectBearer, DetectAWSKey, DetectAssignment, DetectCardNumber, DetectEmail}
+
+// DefaultDenyClaims names of claims that usually hold secrets.
+var DefaultDenyClaims = []string{
+	"password", "passwd", "secret", "token", "access_token", "refresh_token", "client_secret", "api_key", "apikey", "authorization", "cookie", "set-cookie",
+}
+
+// ScrubOptions configure how entries are redacted before they're written anywhere.
+type ScrubOptions struct {
+	// DenyClaims names of claims whose values are always redacted. Names are matched ignoring case,
+	// against the whole name and its last part e.g. `password` matches `user.password`.
+	DenyClaims []string
+	// Patterns redacted from messages and string claims. If a pattern has a group named `secret` only the group is redacted.
+	Patterns []*regexp.Regexp
+	// Detectors redacted from messages and string claims e.g. DefaultDetectors.
+	Detectors []Detector
+	// Mask replaces redacted values. Defaults to DefaultMask.
+	Mask string
+	// Hash replaces redacted values with a hash of the value so that equal values can still be matched without being revealed.
+	Hash bool
+	// HashKey if set, hashes are an HMAC with the key which prevents guessing values by hashing candidates.
+	HashKey []byte
+}
+
+type scrubber struct {
+	opts       ScrubOptions
+	denyClaims map[string]bool
+	detectors  []Detector
+}
+
+var activeScrubber atomic.Value
+
+// SetScrubbing redacts secrets and personal data from entries before they're written to alt4 or emitted.
+// Entries that are redacted have the claim `redacted`. Call with empty options to stop redacting.
+// Example: service.SetScrubbing(service.ScrubOptions{DenyClaims: service.DefaultDenyClaims, Detectors: service.DefaultDetectors})
+func SetScrubbing(opts ScrubOptions) {
+	if opts.Mask == "" {
+		opts.Mask = DefaultMask
+	}
+	s := &scrubber{opts: opts, denyClaims: map[string]bool{}}
+	for _, name := range opts.DenyClaims {
+		s.denyClaims[strings.ToLower(name)] = true
+	}
+	for _, pattern := range opts.Patterns {
+		s.detectors = append(s.detectors, Detector{Name: "pattern", Pattern: pattern})
+	}
+	s.detectors = append(s.detectors, opts.Detectors...)
+	if len(s.denyClaims) == 0 && len(s.detectors) == 0 {
+		s = nil
+	}
+	activeScrubber.Store(s)
+}
+
+func getScrubber() *scrubber {
+	s, _ := activeScrubber.Load().(*scrubber)
+	return s
+}
+
+// scrub redacts the message and claims of an entry. Claims are copied before they're changed since they may be shared between entries.
+func (s *scrubber) scrub(msg *proto.Log) {
+	reasons := map[string]bool{}
+	msg.Message = s.scrubString(msg.Message, reasons)
+
+	var claims []*proto.Claim
+	for i, claim := range msg.Claims {
+		value, claimType := claim.Value, claim.Type
+		if s.denied(claim.Name) {
+			value, claimType = s.replace(claim.Value), proto.Claim_STRING
+			reasons[claim.Name] = true
+		} else if claim.Type == proto.Claim_STRING {
+			value = s.scrubString(claim.Value, reasons)
+		}
+		if value != claim.Value && claims == nil {
+			claims = append(make([]*proto.Claim, 0, len(msg.Claims)+2), msg.Claims[:i]...)
+		}
+		if claims == nil {
+			continue
+		}
+		if value != claim.Value {
+			claims = append(claims, &proto.Claim{Name: claim.Name, Type: claimType, Value: value})
+		} else {
+			claims = append(claims, claim)
+		}
+	}
+	if len(reasons) == 0 {
+		return
+	}
+	if claims == nil {
+		claims = append(make([]*proto.Claim, 0, len(msg.Claims)+2), msg.Claims...)
+	}
+	by := make([]string, 0, len(reasons))
+	for reason := range reasons {
+		by = append(by, reason)
+	}
+	sort.Strings(by)
+	msg.Claims = append(claims,
+		&proto.Claim{Name: RedactedClaim, Type: proto.Claim_BOOLEAN, Value: "true"},
+		&proto.Claim{Name: RedactedClaim + ".by", Type: proto.Claim_STRING, Value: strings.Join(by, ",")},
+	)
+}
+
+func (s *scrubber) denied(name string) bool {
+	if len(s.denyClaims) == 0 {
+		return false
+	}
+	name = strings.ToLower(name)
+	if s.denyClaims[name] {
+		return true
+	}
+	if index := strings.LastIndexByte(name, '.'); index >= 0 {
+		return s.denyClaims[name[index+1:]]
+	}
+	return false
+}
+
+// scrubString redacts what the detectors find. The names of detectors that found something are added to reasons.
+func (s *scrubber) scrubString(value string, reasons map[string]bool) string {
+	for _, detector := range s.detectors {
+		secret := secretGroup(detector.Pattern)
+		matches := detector.Pattern.FindAllStringSubmatchIndex(value, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		var builder strings.Builder
+		last, replaced := 0, false
+		for _, match := range matches {
+			start, end := match[0], match[1]
+			if secret > 0 && match[2*secret] >= 0 {
+				start, end = match[2*secret], match[2*secret+1]
+			}
+			if detector.Valid != nil && !detector.Valid(value[start:end]) {
+				continue
+			}
+			builder.WriteString(value[last:start])
+			builder.WriteString(s.replace(value[start:end]))
+			last, replaced = end, true
+			reasons[detector.Name] = true
+		}
+		if replaced {
+			builder.WriteString(value[last:])
+			value = builder.String()
+		}
+	}
+	return value
+}
+
+// secretGroup returns the index of the group named `secret` or -1.
+func secretGroup(pattern *regexp.Regexp) int {
+	for i, name := range pattern.SubexpNames() {
+		if name == "secret" {
+			return i
+		}
+	}
+	return -1
+}
+
+// replace returns the mask or the hash of a value.
+func (s *scrubber) replace(value string) string {
+	if !s.opts.Hash {
+		return s.opts.Mask
+	}
+	var sum []byte
+	if len(s.opts.HashKey) > 0 {
+		mac := hmac.New(sha256.New, s.opts.HashKey)
+		mac.Write([]byte(value))
+		sum = mac.Sum(nil)
+	} else {
+		hash := sha256.Sum256([]byte(value))
+		sum = hash[:]
+	}
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// luhn validates the checksum of card numbers.
+func luhn(number string) bool {
+	sum, digits := 0, 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c == ' ' || c == '-' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+		double = !double
+	}
+	return digits >= 13 && sum%10 == 0
+}
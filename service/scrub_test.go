@@ -0,0 +1,91 @@
+package service
+
+import (
+	"github.com/alt4dev/protobuff/proto"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetScrubbing(t *testing.T) {
+	defer releaseMode()()
+	defer SetScrubbing(ScrubOptions{})
+	Alt4RemoteHelper = remoteHelperMock{}
+	var logged *proto.Log
+	writeMock = func(msg *proto.Log) {
+		logged = msg
+	}
+	claimOf := func(name string) *proto.Claim {
+		for _, claim := range logged.Claims {
+			if claim.Name == name {
+				return claim
+			}
+		}
+		return nil
+	}
+
+	SetScrubbing(ScrubOptions{
+		DenyClaims: DefaultDenyClaims,
+		Patterns:   []*regexp.Regexp{regexp.MustCompile(`ssn=(?P<secret>\d+)`)},
+		Detectors:  DefaultDetectors,
+	})
+	password := &proto.Claim{Name: "user.Password", Type: proto.Claim_STRING, Value: "hunter2"}
+	contact := &proto.Claim{Name: "contact", Type: proto.Claim_STRING, Value: "jane@example.com"}
+	count := &proto.Claim{Name: "count", Type: proto.Claim_NUMBER, Value: "4111111111111111"}
+	_, _ = LogCaller("main.go", 1, "main.main", false,
+		"paid with 4111 1111 1111 1111 using Authorization: Bearer abc.def-123 ssn=123456 order 1234567890123",
+		[]*proto.Claim{password, contact, count}, proto.Log_INFO, time.Now()).Result()
+
+	if logged.Message != "paid with REDACTED using Authorization: Bearer REDACTED ssn=REDACTED order 1234567890123" {
+		t.Errorf("Unexpected message %q", logged.Message)
+	}
+	if claim := claimOf("user.Password"); claim.Value != DefaultMask {
+		t.Errorf("Expected the denied claim to be redacted. Found %v", claim)
+	}
+	if claim := claimOf("contact"); claim.Value != DefaultMask {
+		t.Errorf("Expected the email to be redacted. Found %v", claim)
+	}
+	if claim := claimOf("count"); claim != count {
+		t.Errorf("Expected numbers to be left as they are. Found %v", claim)
+	}
+	if password.Value != "hunter2" || contact.Value != "jane@example.com" {
+		t.Error("Expected the claims provided to be left unchanged")
+	}
+	if claim := claimOf(RedactedClaim); claim == nil || claim.Value != "true" {
+		t.Errorf("Expected the redacted claim. Found %v", claim)
+	}
+	if claim := claimOf(RedactedClaim + ".by"); claim == nil || claim.Value != "bearer_token,card_number,email,pattern,user.Password" {
+		t.Errorf("Unexpected reasons %v", claim)
+	}
+
+	// Entries without secrets aren't changed
+	claims := []*proto.Claim{count}
+	_, _ = LogCaller("main.go", 1, "main.main", false, "nothing to hide", claims, proto.Log_INFO, time.Now()).Result()
+	if len(logged.Claims) != 1 || logged.Claims[0] != count {
+		t.Errorf("Unexpected claims %v", logged.Claims)
+	}
+
+	// Hashing keeps equal values equal
+	SetScrubbing(ScrubOptions{DenyClaims: []string{"token"}, Hash: true, HashKey: []byte("key")})
+	_, _ = LogCaller("main.go", 1, "main.main", false, "login", []*proto.Claim{{Name: "token", Value: "abc"}}, proto.Log_INFO, time.Now()).Result()
+	first := claimOf("token").Value
+	_, _ = LogCaller("main.go", 1, "main.main", false, "login", []*proto.Claim{{Name: "token", Value: "abc"}}, proto.Log_INFO, time.Now()).Result()
+	if !strings.HasPrefix(first, "sha256:") || first != claimOf("token").Value || strings.Contains(first, "abc") {
+		t.Errorf("Expected a stable hash. Found %s %s", first, claimOf("token").Value)
+	}
+
+	SetScrubbing(ScrubOptions{})
+	_, _ = LogCaller("main.go", 1, "main.main", false, "jane@example.com", nil, proto.Log_INFO, time.Now()).Result()
+	if logged.Message != "jane@example.com" {
+		t.Error("Expected scrubbing to be disabled")
+	}
+}
+
+func TestLuhn(t *testing.T) {
+	for number, valid := range map[string]bool{"4111 1111 1111 1111": true, "5500-0000-0000-0004": true, "1234567890123": false, "0000": false} {
+		if luhn(number) != valid {
+			t.Errorf("Expected luhn(%s) to be %v", number, valid)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"github.com/alt4dev/protobuff/proto"
+	"io"
+	"log"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// LevelRule detects the level of a message written by a standard library logger.
+// Text matched by Pattern is removed from the message.
+type LevelRule struct {
+	Pattern *regexp.Regexp
+	Level   proto.Log_Level
+}
+
+// DefaultLevelRules detect level markers at the start of a message e.g. `[ERROR]`, `WARN:`.
+// Plain words aren't markers so messages like `Error rate is 5%` are left as is.
+var DefaultLevelRules = []LevelRule{
+	{levelMarker("trace|debug"), proto.Log_DEBUG},
+	{levelMarker("info"), proto.Log_INFO},
+	{levelMarker("warn|warning"), proto.Log_WARNING},
+	{levelMarker("error"), proto.Log_ERROR},
+	{levelMarker("fatal|panic|critical"), proto.Log_FATAL},
+}
+
+// levelMarker matches `[LEVEL]` or `LEVEL:` at the start of a message for any of the levels separated by `|`.
+func levelMarker(levels string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i)^\s*(?:\[(?:` + levels + `)\]:?|(?:` + levels + `):)(?:\s+|$)`)
+}
+
+// WriterOptions configure a writer created by NewWriter.
+type WriterOptions struct {
+	// Flags the flags of the standard library logger writing to the writer e.g. log.LstdFlags.
+	// The date, time and file added by the logger are removed from the message.
+	// The file and line are used as the caller when log.Lshortfile or log.Llongfile is set.
+	Flags int
+	// Prefix the prefix of the standard library logger writing to the writer. It's removed from the message.
+	Prefix string
+	// LevelRules detect the level of a message. The first matching rule is used. Defaults to DefaultLevelRules.
+	// Set to an empty slice to disable level detection.
+	LevelRules []LevelRule
+	// DefaultLevel the level used when no rule matches. Defaults to INFO.
+	DefaultLevel proto.Log_Level
+	// CallDepth the number of stack frames to skip from Write to reach the caller.
+	// It's used when the caller can't be read from the output of the logger.
+	// Defaults to 3 which matches the functions and methods of the standard `log` package.
+	// Add one for every function wrapping the logger. A negative depth leaves the caller empty.
+	CallDepth int
+	// Claims added to every entry. Use `log.Claims{...}.ProtoClaims()` to create them.
+	Claims []*proto.Claim
+	// Sync waits for each entry to be written before Write returns.
+	Sync bool
+}
+
+type stdWriter struct {
+	opts   WriterOptions
+	header *regexp.Regexp
+}
+
+// NewWriter creates a writer for standard library loggers that's aware of the logger's flags and prefix.
+// Unlike Writer, the date, time and prefix added by the logger are removed from messages,
+// levels are detected from markers like `[ERROR]` and the caller is read from the logger's output when available.
+// Example: log.SetOutput(service.NewWriter(service.WriterOptions{Flags: log.Flags(), Prefix: log.Prefix()}))
+func NewWriter(opts WriterOptions) io.Writer {
+	if opts.LevelRules == nil {
+		opts.LevelRules = DefaultLevelRules
+	}
+	if opts.DefaultLevel == proto.Log_NONE {
+		opts.DefaultLevel = proto.Log_INFO
+	}
+	if opts.CallDepth == 0 {
+		opts.CallDepth = 3
+	}
+	return &stdWriter{opts: opts, header: headerPattern(opts.Flags, opts.Prefix)}
+}
+
+// headerPattern matches the header written by a standard library logger. The file and line are captured.
+func headerPattern(flags int, prefix string) *regexp.Regexp {
+	pattern := "^"
+	if flags&log.Lmsgprefix == 0 {
+		pattern += regexp.QuoteMeta(prefix)
+	}
+	if flags&log.Ldate != 0 {
+		pattern += `\d{4}/\d{2}/\d{2} `
+	}
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		pattern += `\d{2}:\d{2}:\d{2}`
+		if flags&log.Lmicroseconds != 0 {
+			pattern += `\.\d{6}`
+		}
+		pattern += " "
+	}
+	if flags&(log.Lshortfile|log.Llongfile) != 0 {
+		pattern += `(.+?):(\d+): `
+	}
+	if flags&log.Lmsgprefix != 0 {
+		pattern += regexp.QuoteMeta(prefix)
+	}
+	return regexp.MustCompile(pattern)
+}
+
+func (writer *stdWriter) Write(p []byte) (n int, err error) {
+	t := LogTime()
+	message := strings.TrimSuffix(string(p), "\n")
+
+	var file, function string
+	var line int
+	if match := writer.header.FindStringSubmatch(message); match != nil {
+		message = message[len(match[0]):]
+		if len(match) == 3 {
+			file = match[1]
+			line, _ = strconv.Atoi(match[2])
+		}
+	}
+	if file == "" && writer.opts.CallDepth > 0 {
+		pc, callerFile, callerLine, ok := runtime.Caller(writer.opts.CallDepth)
+		if ok {
+			file, line = callerFile, callerLine
+			function = runtime.FuncForPC(pc).Name()
+		}
+	}
+
+	level := writer.opts.DefaultLevel
+	for _, rule := range writer.opts.LevelRules {
+		if match := rule.Pattern.FindStringIndex(message); match != nil {
+			level = rule.Level
+			message = message[:match[0]] + message[match[1]:]
+			break
+		}
+	}
+
+	result := LogCaller(file, line, function, false, message, writer.opts.Claims, level, t)
+	if writer.opts.Sync {
+		_, err = result.Result()
+	}
+	return len(p), err
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"github.com/alt4dev/protobuff/proto"
+	"log"
+	"runtime"
+	"testing"
+)
+
+func whereAmI() int {
+	_, _, l, _ := runtime.Caller(1)
+	return l
+}
+
+func TestNewWriter(t *testing.T) {
+	defer releaseMode()()
+	Alt4RemoteHelper = remoteHelperMock{}
+	var logged *proto.Log
+	writeMock = func(msg *proto.Log) {
+		logged = msg
+	}
+
+	claims := []*proto.Claim{{Name: "service", Value: "api"}}
+	logger := log.New(NewWriter(WriterOptions{Flags: log.LstdFlags | log.Lmicroseconds | log.Lshortfile, Prefix: "[app] ", Claims: claims, Sync: true}), "[app] ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	line := whereAmI() + 1
+	logger.Println("[ERROR] connection lost")
+	if logged == nil || logged.Message != "connection lost" || logged.Level != proto.Log_ERROR {
+		t.Fatalf("Expected the header and level marker to be removed. Found %v", logged)
+	}
+	if logged.File != "std_writer_test.go" || logged.Line != uint32(line) {
+		t.Errorf("Expected the caller to be read from the output. %s:%d", logged.File, logged.Line)
+	}
+	if len(logged.Claims) != 1 || logged.Claims[0].Name != "service" {
+		t.Errorf("Expected the base claims. Found %v", logged.Claims)
+	}
+
+	// Without file flags the caller is read from the stack
+	_, file, _, _ := runtime.Caller(0)
+	logger = log.New(NewWriter(WriterOptions{Flags: log.Ltime | log.Lmsgprefix, Prefix: "app: ", Sync: true}), "app: ", log.Ltime|log.Lmsgprefix)
+	line = whereAmI() + 1
+	logger.Printf("warn: disk at %d%%", 91)
+	if logged.Message != "disk at 91%" || logged.Level != proto.Log_WARNING {
+		t.Errorf("Unexpected entry %v", logged)
+	}
+	if logged.File != file || logged.Line != uint32(line) {
+		t.Errorf("Expected the caller to be read from the stack. %s:%d", logged.File, logged.Line)
+	}
+
+	// Wrapping the logger requires a deeper call depth
+	logger = log.New(NewWriter(WriterOptions{CallDepth: 4, LevelRules: []LevelRule{}, Sync: true}), "", 0)
+	wrapper := func(v ...interface{}) {
+		logger.Println(v...)
+	}
+	line = whereAmI() + 1
+	wrapper("[ERROR] not detected")
+	if logged.Message != "[ERROR] not detected" || logged.Level != proto.Log_INFO || logged.Line != uint32(line) {
+		t.Errorf("Unexpected entry %v", logged)
+	}
+}
+
+func TestDefaultLevelRules(t *testing.T) {
+	messages := map[string]proto.Log_Level{
+		"[ERROR] connection lost":  proto.Log_ERROR,
+		"error: connection lost":   proto.Log_ERROR,
+		"  [Warn]: disk full":      proto.Log_WARNING,
+		"DEBUG: cache miss":        proto.Log_DEBUG,
+		"panic: nil map":           proto.Log_FATAL,
+		"Error rate is 5%":         proto.Log_NONE,
+		"Info about user":          proto.Log_NONE,
+		"debugging the cache":      proto.Log_NONE,
+		"[error rate] is 5%":       proto.Log_NONE,
+		"errors: none so far":      proto.Log_NONE,
+		"warning signs everywhere": proto.Log_NONE,
+	}
+	for message, expected := range messages {
+		level := proto.Log_NONE
+		for _, rule := range DefaultLevelRules {
+			if rule.Pattern.MatchString(message) {
+				level = rule.Level
+				break
+			}
+		}
+		if level != expected {
+			t.Errorf("Unexpected level for `%s`. %s != %s", message, level, expected)
+		}
+	}
+}
@@ -35,13 +35,27 @@ func getThreadId() string {
 	return uuid.New().String()
 }
 
-func initGroup() {
+// initGroup starts a group for the current routine. A new thread id is generated if threadId is empty.
+func initGroup(threadId string) {
 	routineId := getRoutineId()
 	if _, ok := threads.Load(routineId); ok {
 		threads.Delete(routineId)
 		emitWarning.Println("Unclosed log group detected. Call `defer group.Close()` after initializing group to avoid memory leaks. Better yet do `defer Group(title, claims).Close()`")
 	}
-	threads.Store(routineId, getThreadId())
+	if threadId == "" {
+		threadId = getThreadId()
+	}
+	threads.Store(routineId, threadId)
+}
+
+// ThreadId returns the thread id of the group open in the calling goroutine.
+// ok is false if the goroutine isn't within a group.
+func ThreadId() (threadId string, ok bool) {
+	val, ok := threads.Load(getRoutineId())
+	if !ok {
+		return "", false
+	}
+	return val.(string), true
 }
 
 func CloseGroup() {
@@ -67,3 +81,50 @@ func WaitGroup() *sync.WaitGroup {
 	return wg.(*sync.WaitGroup)
 }
 
+
+// routine the group and wait group of a goroutine. It's used to write entries on behalf of a goroutine from another one e.g. a timer.
+type routine struct {
+	id       string
+	threadId string
+	grouped  bool
+	wg       *sync.WaitGroup
+}
+
+// currentRoutine returns the group and wait group of the calling goroutine.
+func currentRoutine() *routine {
+	r := &routine{id: getRoutineId(), wg: WaitGroup()}
+	if val, ok := threads.Load(r.id); ok {
+		r.threadId, r.grouped = val.(string), true
+	}
+	return r
+}
+
+// do calls f as if from the goroutine of r i.e. entries logged by f are within its group and waited for by its wait group.
+func (r *routine) do(f func()) {
+	routineId := getRoutineId()
+	if routineId == r.id {
+		f()
+		return
+	}
+	previousThread, hadThread := threads.Load(routineId)
+	previousWg, hadWg := waitGroups.Load(routineId)
+	if r.grouped {
+		threads.Store(routineId, r.threadId)
+	} else {
+		threads.Delete(routineId)
+	}
+	waitGroups.Store(routineId, r.wg)
+	defer func() {
+		if hadThread {
+			threads.Store(routineId, previousThread)
+		} else {
+			threads.Delete(routineId)
+		}
+		if hadWg {
+			waitGroups.Store(routineId, previousWg)
+		} else {
+			waitGroups.Delete(routineId)
+		}
+	}()
+	f()
+}
@@ -57,7 +57,7 @@ func TestGrouping(t *testing.T) {
 	}
 
 	// Start a group
-	initGroup()
+	initGroup("")
 
 	currentThread := getThreadId()
 	// Confirm that with grouping, the thread ID doesn't change
@@ -69,7 +69,7 @@ func TestGrouping(t *testing.T) {
 	f, _ := os.Open(os.DevNull)
 	emitWarning.SetOutput(f) // suppress warning log
 	// Confirm that initializing a group without closing replaces the older one
-	initGroup()
+	initGroup("")
 	emitWarning.SetOutput(options.Writer) // restore
 	newThreadId := getThreadId()
 	if newThreadId == currentThread {
@@ -85,3 +85,24 @@ func TestGrouping(t *testing.T) {
 		t.Error("Routine id should be deleted from threads list after closing a thread")
 	}
 }
+
+func TestJoinGroup(t *testing.T) {
+	if _, ok := ThreadId(); ok {
+		t.Error("ThreadId should not be available for ungrouped routines")
+		return
+	}
+
+	// Joining a group continues the thread provided
+	initGroup("a-thread-from-another-service")
+	if threadId, ok := ThreadId(); !ok || threadId != "a-thread-from-another-service" {
+		t.Errorf("Expected the joined thread id. Found '%s'", threadId)
+	}
+	if getThreadId() != "a-thread-from-another-service" {
+		t.Error("Logs within a joined group should use the joined thread id")
+	}
+
+	CloseGroup()
+	if _, ok := ThreadId(); ok {
+		t.Error("ThreadId should not be available after closing a group")
+	}
+}
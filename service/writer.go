@@ -21,6 +21,7 @@ func (writer alt4SyncWriter) Write(p []byte) (n int, err error) {
 // Writer can be used to override a normal/default go logger to write it's output to alt4
 // This method writes logs asynchronously. Opening and Closing a group at the end of your routines ensure waits for all writes to finish.
 // Example log.SetOutput(Writer)
+// Use NewWriter to remove the logger's date and prefix from messages and to detect levels.
 var Writer = alt4Writer{}
 
 // SyncWriter can be used to override a normal/default go logger to write it's output to alt4
@@ -0,0 +1,158 @@
+// Package tracecontext lines up alt4 log groups with W3C Trace Context (https://www.w3.org/TR/trace-context/).
+// Thread ids generated by alt4 are UUIDs which have the same size as a trace id.
+// A trace id received in a `traceparent` header is therefore adopted as the thread id of a group and
+// the thread id of a group is sent as the trace id of outgoing calls.
+package tracecontext
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/alt4dev/go/log"
+	"github.com/google/uuid"
+	"strings"
+)
+
+// Header is the name of the header(or metadata key) carrying the trace context.
+const Header = "traceparent"
+
+// ErrInvalidTraceparent is returned when parsing a malformed `traceparent` value.
+var ErrInvalidTraceparent = errors.New("tracecontext: invalid traceparent")
+
+// SpanContext identifies a span within a trace.
+type SpanContext struct {
+	// TraceId 32 lowercase hex characters identifying the trace.
+	TraceId string
+	// SpanId 16 lowercase hex characters identifying the span.
+	SpanId string
+	// ParentSpanId the id of the span that started this one. Empty for the first span of a trace.
+	ParentSpanId string
+	// Flags trace flags e.g. 01 when the trace is sampled.
+	Flags byte
+}
+
+// Parse decodes a `traceparent` header value. The returned span context has the received span as its parent.
+// Call Child to get the span of the current service.
+func Parse(traceparent string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	version, traceId, spanId, flags := parts[0], parts[1], parts[2], parts[3]
+	// Version 00 has exactly 4 parts, future versions may append more
+	if version == "ff" || (version == "00" && len(parts) != 4) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	if !isHex(version) || !isHex(traceId) || !isHex(spanId) || !isHex(flags) || isZero(traceId) || isZero(spanId) {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	flagBytes, _ := hex.DecodeString(flags)
+	return SpanContext{TraceId: traceId, SpanId: spanId, Flags: flagBytes[0]}, nil
+}
+
+// Traceparent encodes the span context as a `traceparent` header value.
+func (span SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", span.TraceId, span.SpanId, span.Flags)
+}
+
+// Child returns a new span within the same trace whose parent is span.
+func (span SpanContext) Child() SpanContext {
+	return SpanContext{TraceId: span.TraceId, SpanId: NewSpanId(), ParentSpanId: span.SpanId, Flags: span.Flags}
+}
+
+// ThreadId returns the thread id of the group that should hold the span's logs.
+func (span SpanContext) ThreadId() string {
+	return ThreadId(span.TraceId)
+}
+
+// Claims returns the trace id, span id and parent span id as claims.
+func (span SpanContext) Claims() log.Claims {
+	claims := log.Claims{
+		"trace_id": span.TraceId,
+		"span_id":  span.SpanId,
+	}
+	if span.ParentSpanId != "" {
+		claims["parent_span_id"] = span.ParentSpanId
+	}
+	return claims
+}
+
+// NewSpan starts a new trace for the thread `threadId`. The thread id is used as the trace id if it's a UUID.
+// Otherwise a random trace id is generated.
+func NewSpan(threadId string) SpanContext {
+	traceId, ok := TraceId(threadId)
+	if !ok {
+		traceId = randomHex(16)
+	}
+	return SpanContext{TraceId: traceId, SpanId: NewSpanId(), Flags: 1}
+}
+
+// NewSpanId generates a random span id.
+func NewSpanId() string {
+	return randomHex(8)
+}
+
+// ThreadId converts a trace id into a thread id. Trace ids are formatted as UUIDs like the thread ids generated by alt4.
+func ThreadId(traceId string) string {
+	id, err := uuid.Parse(traceId)
+	if err != nil {
+		return traceId
+	}
+	return id.String()
+}
+
+// TraceId converts a thread id into a trace id. ok is false if the thread id isn't a UUID.
+func TraceId(threadId string) (traceId string, ok bool) {
+	id, err := uuid.Parse(threadId)
+	if err != nil || id == uuid.Nil {
+		return "", false
+	}
+	return hex.EncodeToString(id[:]), true
+}
+
+type contextKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying span. Outgoing calls made with the context are children of span.
+func ContextWithSpan(ctx context.Context, span SpanContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, span)
+}
+
+// FromContext returns the span carried by ctx.
+func FromContext(ctx context.Context) (SpanContext, bool) {
+	span, ok := ctx.Value(contextKey{}).(SpanContext)
+	return span, ok
+}
+
+// Outgoing returns the span of an outgoing call made with ctx from the thread `threadId`.
+// The call is a child of the span carried by ctx if any, otherwise it starts a trace for the thread.
+func Outgoing(ctx context.Context, threadId string) SpanContext {
+	if span, ok := FromContext(ctx); ok {
+		return span.Child()
+	}
+	return NewSpan(threadId)
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	for {
+		_, _ = rand.Read(b)
+		if encoded := hex.EncodeToString(b); !isZero(encoded) {
+			return encoded
+		}
+	}
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
@@ -0,0 +1,67 @@
+package tracecontext
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	span, err := Parse("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if span.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || span.SpanId != "00f067aa0ba902b7" || span.Flags != 1 {
+		t.Errorf("Unexpected span context %v", span)
+	}
+	if span.Traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("Unexpected traceparent %s", span.Traceparent())
+	}
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	}
+	for _, value := range invalid {
+		if _, err := Parse(value); err != ErrInvalidTraceparent {
+			t.Errorf("Expected '%s' to be invalid", value)
+		}
+	}
+	// Future versions may carry extra fields
+	if _, err := Parse("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); err != nil {
+		t.Error("Expected future versions with extra fields to be accepted")
+	}
+}
+
+func TestThreadId(t *testing.T) {
+	threadId := ThreadId("4bf92f3577b34da6a3ce929d0e0e4736")
+	if threadId != "4bf92f35-77b3-4da6-a3ce-929d0e0e4736" {
+		t.Errorf("Unexpected thread id %s", threadId)
+	}
+	if traceId, ok := TraceId(threadId); !ok || traceId != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the trace id to be recovered from the thread id. Found %s", traceId)
+	}
+	if _, ok := TraceId("not-a-uuid"); ok {
+		t.Error("Thread ids that aren't UUIDs can't be used as trace ids")
+	}
+}
+
+func TestOutgoing(t *testing.T) {
+	span := NewSpan("4bf92f35-77b3-4da6-a3ce-929d0e0e4736")
+	if span.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentSpanId != "" || len(span.SpanId) != 16 {
+		t.Errorf("Expected a new trace for the thread. Found %v", span)
+	}
+
+	child := Outgoing(ContextWithSpan(context.Background(), span), "another-thread")
+	if child.TraceId != span.TraceId || child.ParentSpanId != span.SpanId || child.SpanId == span.SpanId {
+		t.Errorf("Expected a child of the span in the context. Found %v", child)
+	}
+	claims := child.Claims()
+	if claims["trace_id"] != span.TraceId || claims["span_id"] != child.SpanId || claims["parent_span_id"] != span.SpanId {
+		t.Errorf("Unexpected claims %v", claims)
+	}
+}